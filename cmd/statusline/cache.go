@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// cacheDir returns the directory for cached state: $STATUSLINE_CACHE_DIR, or
+// "statusline" under the user cache directory ($XDG_CACHE_HOME on Linux).
+func cacheDir() string {
+	if d := os.Getenv("STATUSLINE_CACHE_DIR"); d != "" {
+		return d
+	}
+	if d, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(d, "statusline")
+	}
+	return ""
+}
+
+// cachePath returns the file caching kind for key, e.g. a repository root.
+func cachePath(kind, key string) string {
+	dir := cacheDir()
+	if dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, kind, hex.EncodeToString(sum[:8])+".json")
+}
+
+// readCache decodes the cache file at path into v.
+func readCache(path string, v any) bool {
+	if path == "" {
+		return false
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+// writeCache stores v at path. The file is replaced atomically because
+// several Claude Code sessions may render at the same time.
+func writeCache(path string, v any) {
+	if path == "" {
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, werr := f.Write(b)
+	cerr := f.Close()
+	if werr != nil || cerr != nil || os.Rename(f.Name(), path) != nil {
+		_ = os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheDir(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", "/tmp/statusline-cache")
+	assert.Equal(t, "/tmp/statusline-cache", cacheDir())
+
+	t.Setenv("STATUSLINE_CACHE_DIR", "")
+	t.Setenv("XDG_CACHE_HOME", "/tmp/xdg")
+	if d, err := os.UserCacheDir(); err == nil {
+		assert.Equal(t, filepath.Join(d, "statusline"), cacheDir())
+	}
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	p := cachePath("test", "/some/repo")
+	require.NotEmpty(t, p)
+	assert.NotEqual(t, p, cachePath("test", "/other/repo"))
+
+	var v map[string]int
+	assert.False(t, readCache(p, &v))
+
+	writeCache(p, map[string]int{"a": 1})
+	assert.True(t, readCache(p, &v))
+	assert.Equal(t, map[string]int{"a": 1}, v)
+
+	// corrupt files are ignored
+	require.NoError(t, os.WriteFile(p, []byte("{"), 0o644))
+	assert.False(t, readCache(p, &v))
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// awsInfo is the AWS profile and region of the environment.
+type awsInfo struct {
+	Profile string `json:"profile"`
+	Region  string `json:"region"`
+}
+
+// gcpInfo is the active gcloud configuration and its project.
+type gcpInfo struct {
+	Config  string `json:"config"`
+	Project string `json:"project"`
+}
+
+// awsProfile returns the AWS profile and region of the environment. The
+// region falls back to the profile's entry in the AWS config file. ok is
+// false when neither a profile nor credentials are configured in the
+// environment.
+func awsProfile() (profile, region string, ok bool) {
+	profile = firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_VAULT")
+	region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if profile == "" && region == "" && os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		return "", "", false
+	}
+	if region == "" {
+		section := "default"
+		if profile != "" && profile != "default" {
+			section = "profile " + profile
+		}
+		region = readINI(awsConfigFile())[section]["region"]
+	}
+	return profile, region, true
+}
+
+func awsConfigFile() string {
+	if f := os.Getenv("AWS_CONFIG_FILE"); f != "" {
+		return f
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", "config")
+}
+
+// gcloudConfig returns the active gcloud configuration and its project, read
+// from active_config and configurations/config_<name> in the gcloud config
+// directory.
+func gcloudConfig() (name, project string, ok bool) {
+	dir := gcloudDir()
+	if dir == "" {
+		return "", "", false
+	}
+	name = os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME")
+	if name == "" {
+		b, err := os.ReadFile(filepath.Join(dir, "active_config"))
+		if err != nil {
+			return "", "", false
+		}
+		name = strings.TrimSpace(string(b))
+	}
+	if name == "" {
+		return "", "", false
+	}
+	project = os.Getenv("CLOUDSDK_CORE_PROJECT")
+	if project == "" {
+		project = readINI(filepath.Join(dir, "configurations", "config_"+name))["core"]["project"]
+	}
+	return name, project, true
+}
+
+func gcloudDir() string {
+	if d := os.Getenv("CLOUDSDK_CONFIG"); d != "" {
+		return d
+	}
+	if runtime.GOOS == "windows" {
+		if d := os.Getenv("APPDATA"); d != "" {
+			return filepath.Join(d, "gcloud")
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud")
+}
+
+// azureSubscription returns the name of the default subscription in
+// azureProfile.json.
+func azureSubscription() (string, bool) {
+	dir := os.Getenv("AZURE_CONFIG_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		dir = filepath.Join(home, ".azure")
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "azureProfile.json"))
+	if err != nil {
+		return "", false
+	}
+	// az writes the file with a byte order mark
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+	var profile struct {
+		Subscriptions []struct {
+			Name      string `json:"name"`
+			ID        string `json:"id"`
+			IsDefault bool   `json:"isDefault"`
+		} `json:"subscriptions"`
+	}
+	if json.Unmarshal(b, &profile) != nil {
+		return "", false
+	}
+	for _, s := range profile.Subscriptions {
+		if s.IsDefault {
+			if s.Name == "" {
+				return s.ID, s.ID != ""
+			}
+			return s.Name, true
+		}
+	}
+	return "", false
+}
+
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// readINI reads the sections of an INI file such as the AWS or gcloud
+// config. Keys before the first section belong to "".
+func readINI(path string) map[string]map[string]string {
+	sections := map[string]map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return sections
+	}
+	defer f.Close()
+	section := ""
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		ln := strings.TrimSpace(sc.Text())
+		switch {
+		case ln == "" || ln[0] == '#' || ln[0] == ';':
+		case ln[0] == '[' && ln[len(ln)-1] == ']':
+			section = strings.TrimSpace(ln[1 : len(ln)-1])
+		default:
+			k, v, ok := strings.Cut(ln, "=")
+			if !ok {
+				continue
+			}
+			if sections[section] == nil {
+				sections[section] = map[string]string{}
+			}
+			sections[section][strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return sections
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSProfile(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(cfg, []byte("[default]\nregion = us-east-1\n\n[profile prod]\n# eu\nregion=eu-west-1\n"), 0o600))
+	t.Setenv("AWS_CONFIG_FILE", cfg)
+	vars := []string{"AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_VAULT", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID"}
+
+	tests := []struct {
+		name            string
+		env             map[string]string
+		profile, region string
+		ok              bool
+	}{
+		{"nothing set", nil, "", "", false},
+		{"profile with region from config", map[string]string{"AWS_PROFILE": "prod"}, "prod", "eu-west-1", true},
+		{"region from env", map[string]string{"AWS_PROFILE": "prod", "AWS_REGION": "ap-south-1"}, "prod", "ap-south-1", true},
+		{"aws-vault", map[string]string{"AWS_VAULT": "dev"}, "dev", "", true},
+		{"credentials only", map[string]string{"AWS_ACCESS_KEY_ID": "AKIA"}, "", "us-east-1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range vars {
+				t.Setenv(k, tt.env[k])
+			}
+			profile, region, ok := awsProfile()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.profile, profile)
+			assert.Equal(t, tt.region, region)
+		})
+	}
+}
+
+func TestGcloudConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", dir)
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+	t.Setenv("CLOUDSDK_CORE_PROJECT", "")
+
+	_, _, ok := gcloudConfig()
+	assert.False(t, ok)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "active_config"), []byte("work\n"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "configurations"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "configurations", "config_work"),
+		[]byte("[core]\naccount = me@example.com\nproject = billing-prod\n\n[compute]\nregion = europe-west1\n"), 0o600))
+
+	name, project, ok := gcloudConfig()
+	assert.True(t, ok)
+	assert.Equal(t, "work", name)
+	assert.Equal(t, "billing-prod", project)
+
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "default")
+	name, project, ok = gcloudConfig()
+	assert.True(t, ok)
+	assert.Equal(t, "default", name)
+	assert.Equal(t, "", project)
+}
+
+func TestAzureSubscription(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AZURE_CONFIG_DIR", dir)
+
+	_, ok := azureSubscription()
+	assert.False(t, ok)
+
+	profile := "\ufeff" + `{"installationId": "x", "subscriptions": [
+		{"id": "1", "name": "Dev", "isDefault": false},
+		{"id": "2", "name": "Production", "isDefault": true}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "azureProfile.json"), []byte(profile), 0o600))
+	sub, ok := azureSubscription()
+	assert.True(t, ok)
+	assert.Equal(t, "Production", sub)
+}
+
+func TestCloudSegments(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th, Icons: iconSets["ascii"]}
+	ri := repoInfo{AWS: &awsInfo{Profile: "prod-admin", Region: "eu-west-1"}}
+
+	seg, ok := awsSegment(ri, input{}, opts)
+	require.True(t, ok)
+	assert.Equal(t, []span{{"cloud:", th.Cloud, ""}, {"aws:prod-admin", th.Cloud, ""}, {"eu-west-1", th.Cloud, ""}}, seg.Spans)
+
+	opts.Danger = defaultDanger
+	seg, _ = awsSegment(ri, input{}, opts)
+	assert.Equal(t, th.Danger, seg.Block)
+
+	seg, _ = gcpSegment(repoInfo{GCP: &gcpInfo{Config: "default"}}, input{}, opts)
+	assert.Equal(t, "gcp:default", seg.Spans[1].Text)
+	seg, _ = azureSegment(repoInfo{Azure: "Pay-As-You-Go"}, input{}, opts)
+	assert.Equal(t, "az:Pay-As-You-Go", seg.Spans[1].Text)
+	for _, f := range []segmentFunc{awsSegment, gcpSegment, azureSegment} {
+		_, ok := f(repoInfo{}, input{}, opts)
+		assert.False(t, ok)
+	}
+
+	assert.Equal(t, "cloud: aws:prod-admin eu-west-1", render(ri, input{}, renderOptions{Icons: iconSets["ascii"], Lines: parseLines("aws")}))
+}
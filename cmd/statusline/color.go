@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// colorMode is the value of the --color flag.
+type colorMode int
+
+const (
+	colorAuto colorMode = iota
+	colorAlways
+	colorNever
+)
+
+func parseColorMode(s string) (colorMode, error) {
+	switch s {
+	case "", "auto":
+		return colorAuto, nil
+	case "always":
+		return colorAlways, nil
+	case "never":
+		return colorNever, nil
+	}
+	return colorAuto, fmt.Errorf("invalid color mode %q (want auto, always or never)", s)
+}
+
+// resolveColor decides whether output is colored. An explicit flag wins,
+// otherwise the FORCE_COLOR, NO_COLOR and CLICOLOR conventions are honored.
+// Claude Code reads the statusline through a pipe, so auto does not probe for
+// a terminal and defaults to color.
+func resolveColor(mode colorMode) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+
+	if v := os.Getenv("FORCE_COLOR"); v != "" {
+		return v != "0" && v != "false"
+	}
+	if v := os.Getenv("CLICOLOR_FORCE"); v != "" && v != "0" {
+		return true
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("STATUSLINE_NO_COLOR") == "1" {
+		return false
+	}
+	return os.Getenv("CLICOLOR") != "0"
+}
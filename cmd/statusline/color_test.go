@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColorMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected colorMode
+		wantErr  bool
+	}{
+		{"auto", colorAuto, false},
+		{"", colorAuto, false},
+		{"always", colorAlways, false},
+		{"never", colorNever, false},
+		{"sometimes", colorAuto, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mode, err := parseColorMode(tt.input)
+			assert.Equal(t, tt.expected, mode)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResolveColor(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     colorMode
+		env      map[string]string
+		expected bool
+	}{
+		{"auto defaults to color", colorAuto, nil, true},
+		{"always ignores NO_COLOR", colorAlways, map[string]string{"NO_COLOR": "1"}, true},
+		{"never ignores FORCE_COLOR", colorNever, map[string]string{"FORCE_COLOR": "1"}, false},
+		{"NO_COLOR disables", colorAuto, map[string]string{"NO_COLOR": "1"}, false},
+		{"STATUSLINE_NO_COLOR disables", colorAuto, map[string]string{"STATUSLINE_NO_COLOR": "1"}, false},
+		{"CLICOLOR=0 disables", colorAuto, map[string]string{"CLICOLOR": "0"}, false},
+		{"CLICOLOR=1 keeps color", colorAuto, map[string]string{"CLICOLOR": "1"}, true},
+		{"FORCE_COLOR wins over NO_COLOR", colorAuto, map[string]string{"FORCE_COLOR": "1", "NO_COLOR": "1"}, true},
+		{"FORCE_COLOR=0 disables", colorAuto, map[string]string{"FORCE_COLOR": "0"}, false},
+		{"CLICOLOR_FORCE wins over CLICOLOR", colorAuto, map[string]string{"CLICOLOR_FORCE": "1", "CLICOLOR": "0"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"FORCE_COLOR", "CLICOLOR_FORCE", "NO_COLOR", "STATUSLINE_NO_COLOR", "CLICOLOR"} {
+				t.Setenv(k, tt.env[k])
+			}
+			assert.Equal(t, tt.expected, resolveColor(tt.mode))
+		})
+	}
+}
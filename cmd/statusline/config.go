@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config is read from config.json in the config directory. Every field is
+// optional, and environment variables take precedence over the file.
+type config struct {
+	Theme string `json:"theme"`
+	Mode  string `json:"mode"`
+	Icons string `json:"icons"`
+
+	// Links wraps the project, branch and commit in OSC 8 hyperlinks to the
+	// remote. Forges maps self-hosted forge hosts to their URL templates.
+	Links  bool             `json:"links"`
+	Forges map[string]forge `json:"forges"`
+
+	// Lines lays out the output, one entry per line. Width is the terminal
+	// width used to right-align segments; COLUMNS is used when it is unset.
+	Lines []lineLayout `json:"lines"`
+	Width int          `json:"width"`
+
+	// SubProject shows the package containing cwd next to the repository
+	// name: "path" (default), "name" or "off". Markers are the files that
+	// make a directory a package.
+	SubProject string   `json:"subproject"`
+	Markers    []string `json:"markers"`
+
+	// Danger lists glob patterns of Kubernetes contexts and cloud profiles
+	// that are highlighted, e.g. production ones. "*" matches anything.
+	Danger []string `json:"danger"`
+
+	// Untracked is how git status looks for untracked files: "normal"
+	// (default), "no" or "auto". FSMonitor uses git's builtin file system
+	// monitor.
+	Untracked string `json:"untracked"`
+	FSMonitor bool   `json:"fsmonitor"`
+
+	// Timeout bounds every VCS command (default 300ms), Budget all of them
+	// together in one render (default none).
+	Timeout duration `json:"timeout"`
+	Budget  duration `json:"budget"`
+
+	// Plugins declares custom segments backed by external commands, keyed
+	// by the segment name used in lines.
+	Plugins map[string]plugin `json:"plugins"`
+}
+
+// configDir returns the directory holding config.json and custom themes:
+// $STATUSLINE_CONFIG_DIR, or "statusline" under the user config directory.
+func configDir() string {
+	if d := os.Getenv("STATUSLINE_CONFIG_DIR"); d != "" {
+		return d
+	}
+	if d, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(d, "statusline")
+	}
+	return ""
+}
+
+// loadConfig reads config.json from dir and applies environment overrides.
+// A missing file is not an error; an unreadable or invalid one yields the
+// defaults together with the error so the line still renders.
+func loadConfig(dir string) (config, error) {
+	cfg, err := readConfigFile(dir)
+	cfg.applyEnv()
+	return cfg, err
+}
+
+func readConfigFile(dir string) (config, error) {
+	cfg := config{Markers: defaultMarkers, Danger: defaultDanger}
+	if dir == "" {
+		return cfg, nil
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return config{Markers: defaultMarkers, Danger: defaultDanger}, err
+	}
+	return cfg, nil
+}
+
+func (c *config) applyEnv() {
+	if s := os.Getenv("STATUSLINE_THEME"); s != "" {
+		c.Theme = s
+	}
+	if s := os.Getenv("STATUSLINE_MODE"); s != "" {
+		c.Mode = s
+	}
+	if s := os.Getenv("STATUSLINE_ICONS"); s != "" {
+		c.Icons = s
+	}
+	if s := os.Getenv("STATUSLINE_LINKS"); s != "" {
+		c.Links = s == "1"
+	}
+	if s := os.Getenv("STATUSLINE_LINES"); s != "" {
+		c.Lines = parseLines(s)
+	}
+	if s := os.Getenv("STATUSLINE_SUBPROJECT"); s != "" {
+		c.SubProject = s
+	}
+	if s := os.Getenv("STATUSLINE_MARKERS"); s != "" {
+		c.Markers = splitNames(s)
+	}
+	if s := os.Getenv("STATUSLINE_UNTRACKED"); s != "" {
+		c.Untracked = s
+	}
+	if s := os.Getenv("STATUSLINE_FSMONITOR"); s != "" {
+		c.FSMonitor = s == "1"
+	}
+	if d, err := time.ParseDuration(os.Getenv("STATUSLINE_TIMEOUT")); err == nil {
+		c.Timeout = duration(d)
+	}
+	if d, err := time.ParseDuration(os.Getenv("STATUSLINE_BUDGET")); err == nil {
+		c.Budget = duration(d)
+	}
+	if s := os.Getenv("STATUSLINE_DANGER"); s != "" {
+		c.Danger = splitNames(s)
+	}
+	if n, err := strconv.Atoi(os.Getenv("STATUSLINE_WIDTH")); err == nil && n > 0 {
+		c.Width = n
+	} else if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 && c.Width == 0 {
+		c.Width = n
+	}
+}
+
+// applyVCS sets how VCS commands run: the untracked files mode, fsmonitor
+// and the timeout of each command.
+func (c config) applyVCS() {
+	if c.Untracked != "" {
+		gitOptions.Untracked = c.Untracked
+	}
+	gitOptions.FSMonitor = c.FSMonitor
+	if c.Timeout > 0 {
+		runTimeout = time.Duration(c.Timeout)
+	}
+}
+
+// parseLines reads the STATUSLINE_LINES shorthand: lines separated by ";",
+// segment names by "," and the right group after "|", e.g.
+// "project,git,sync|clock;model,context,cost".
+func parseLines(s string) []lineLayout {
+	var lines []lineLayout
+	for l := range strings.SplitSeq(s, ";") {
+		left, right, _ := strings.Cut(l, "|")
+		line := lineLayout{Segments: splitNames(left), Right: splitNames(right)}
+		if len(line.Segments) > 0 || len(line.Right) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func splitNames(s string) []string {
+	var names []string
+	for name := range strings.SplitSeq(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigDir(t *testing.T) {
+	t.Setenv("STATUSLINE_CONFIG_DIR", "/custom/dir")
+	assert.Equal(t, "/custom/dir", configDir())
+
+	t.Setenv("STATUSLINE_CONFIG_DIR", "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if d, err := os.UserConfigDir(); err == nil {
+		assert.Equal(t, filepath.Join(d, "statusline"), configDir())
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("STATUSLINE_THEME", "")
+
+	t.Run("missing file", func(t *testing.T) {
+		cfg, err := loadConfig(t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, config{Markers: defaultMarkers, Danger: defaultDanger}, cfg)
+	})
+
+	t.Run("no config dir", func(t *testing.T) {
+		cfg, err := loadConfig("")
+		require.NoError(t, err)
+		assert.Equal(t, config{Markers: defaultMarkers, Danger: defaultDanger}, cfg)
+	})
+
+	t.Run("theme from file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"theme": "gruvbox"}`), 0o644))
+		cfg, err := loadConfig(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "gruvbox", cfg.Theme)
+	})
+
+	t.Run("env overrides file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"theme": "gruvbox"}`), 0o644))
+		t.Setenv("STATUSLINE_THEME", "solarized")
+		cfg, err := loadConfig(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "solarized", cfg.Theme)
+	})
+
+	t.Run("danger patterns", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"danger": []}`), 0o644))
+		cfg, err := loadConfig(dir)
+		require.NoError(t, err)
+		assert.Empty(t, cfg.Danger)
+
+		t.Setenv("STATUSLINE_DANGER", "*prod*, live-*")
+		cfg, _ = loadConfig(dir)
+		assert.Equal(t, []string{"*prod*", "live-*"}, cfg.Danger)
+	})
+
+	t.Run("timeouts", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"timeout": "500ms", "budget": "1s"}`), 0o644))
+		t.Setenv("STATUSLINE_TIMEOUT", "")
+		t.Setenv("STATUSLINE_BUDGET", "")
+		cfg, err := loadConfig(dir)
+		require.NoError(t, err)
+		assert.Equal(t, duration(500*time.Millisecond), cfg.Timeout)
+		assert.Equal(t, duration(time.Second), cfg.Budget)
+
+		t.Setenv("STATUSLINE_TIMEOUT", "1s")
+		t.Setenv("STATUSLINE_BUDGET", "nonsense")
+		cfg, _ = loadConfig(dir)
+		assert.Equal(t, duration(time.Second), cfg.Timeout)
+		assert.Equal(t, duration(time.Second), cfg.Budget)
+	})
+
+	t.Run("invalid file keeps env", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"theme":`), 0o644))
+		t.Setenv("STATUSLINE_THEME", "monochrome")
+		cfg, err := loadConfig(dir)
+		assert.Error(t, err)
+		assert.Equal(t, "monochrome", cfg.Theme)
+	})
+}
+
+func TestParseLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []lineLayout
+	}{
+		{"single line", "project,git", []lineLayout{{Segments: []string{"project", "git"}}}},
+		{
+			"two lines with spaces", "project, git ,sync; model,cost",
+			[]lineLayout{{Segments: []string{"project", "git", "sync"}}, {Segments: []string{"model", "cost"}}},
+		},
+		{"empty parts", ";project,,;", []lineLayout{{Segments: []string{"project"}}}},
+		{
+			"right group", "project,git|cost,clock;|clock",
+			[]lineLayout{
+				{Segments: []string{"project", "git"}, Right: []string{"cost", "clock"}},
+				{Right: []string{"clock"}},
+			},
+		},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseLines(tt.input))
+		})
+	}
+}
+
+func TestConfigWidth(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"width": 100}`), 0o644))
+
+	t.Setenv("STATUSLINE_WIDTH", "")
+	t.Setenv("COLUMNS", "80")
+	cfg, _ := loadConfig(dir)
+	assert.Equal(t, 100, cfg.Width, "config wins over COLUMNS")
+
+	cfg, _ = loadConfig(t.TempDir())
+	assert.Equal(t, 80, cfg.Width, "COLUMNS when unset")
+
+	t.Setenv("STATUSLINE_WIDTH", "120")
+	cfg, _ = loadConfig(dir)
+	assert.Equal(t, 120, cfg.Width, "env wins over config")
+
+	t.Setenv("STATUSLINE_WIDTH", "")
+	t.Setenv("COLUMNS", "wide")
+	cfg, _ = loadConfig(t.TempDir())
+	assert.Equal(t, 0, cfg.Width)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// daemonPoll is how long collected state is served for repositories
+	// that can't be watched.
+	daemonPoll = time.Second
+
+	// daemonTimeout bounds a client request before the client collects by
+	// itself. Answers from memory take well under a millisecond; a daemon
+	// that has to collect first is not waited for.
+	daemonTimeout = 20 * time.Millisecond
+
+	// daemonForget is how long the state of a directory is kept without
+	// requests for it.
+	daemonForget = 10 * time.Minute
+)
+
+// watcher reports changes below the repository roots added to it.
+type watcher interface {
+	Add(root string) error
+	Close() error
+}
+
+type daemonRequest struct {
+	Cwd string `json:"cwd"`
+}
+
+// daemon serves repoInfo for directories, collecting it again only after the
+// watcher reported a change in the repository.
+type daemon struct {
+	collect func(cwd string) repoInfo
+	w       watcher
+
+	mu      sync.Mutex
+	entries map[string]daemonEntry // by cwd
+	gen     map[string]int         // changes seen per watched root, -1 when polled
+	last    time.Time              // last request
+}
+
+type daemonEntry struct {
+	ri   repoInfo
+	at   time.Time // when collected
+	used time.Time // last request
+	gen  int       // gen of the root when collected, -1 when not watched
+}
+
+func newDaemon(collect func(string) repoInfo) *daemon {
+	d := &daemon{
+		collect: collect,
+		entries: map[string]daemonEntry{},
+		gen:     map[string]int{},
+		last:    time.Now(),
+	}
+	if w, err := newWatcher(d.changed, d.dropped); err == nil {
+		d.w = w
+	}
+	return d
+}
+
+// changed counts a change in root. A root only counts as watched once Add
+// succeeded, so events of roots that are not fully watched are ignored.
+func (d *daemon) changed(root string) {
+	d.mu.Lock()
+	if g, ok := d.gen[root]; ok && g >= 0 {
+		d.gen[root]++
+	}
+	d.mu.Unlock()
+}
+
+// dropped polls root from now on, as the watcher no longer watches it.
+func (d *daemon) dropped(root string) {
+	d.mu.Lock()
+	d.gen[root] = -1
+	d.mu.Unlock()
+}
+
+// get returns the state of the repository containing cwd.
+func (d *daemon) get(cwd string) repoInfo {
+	d.mu.Lock()
+	d.last = time.Now()
+	e, ok := d.entries[cwd]
+	if ok && d.fresh(e) {
+		e.used = d.last
+		d.entries[cwd] = e
+		d.mu.Unlock()
+		return e.ri
+	}
+	d.mu.Unlock()
+
+	ri := d.collect(cwd)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e = daemonEntry{ri: ri, at: time.Now(), used: time.Now(), gen: -1}
+	if g, known := d.gen[ri.Root]; known {
+		e.gen = g
+	} else if ri.IsRepo && d.w != nil {
+		if d.w.Add(ri.Root) == nil {
+			// changes made while collecting were missed; the next request
+			// collects again with the watch in place
+			d.gen[ri.Root] = 0
+		} else {
+			// too large to watch; polled without walking it again
+			d.gen[ri.Root] = -1
+		}
+	}
+	d.entries[cwd] = e
+	return ri
+}
+
+func (d *daemon) fresh(e daemonEntry) bool {
+	if e.ri.Stale {
+		return false
+	}
+	if g, known := d.gen[e.ri.Root]; known && g >= 0 && e.ri.IsRepo {
+		return e.gen == g
+	}
+	return time.Since(e.at) < daemonPoll
+}
+
+// forget drops the state of directories not asked for within age.
+func (d *daemon) forget(age time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for cwd, e := range d.entries {
+		if time.Since(e.used) > age {
+			delete(d.entries, cwd)
+		}
+	}
+}
+
+func (d *daemon) idle() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return time.Since(d.last)
+}
+
+// serve answers requests on l until it is closed.
+func (d *daemon) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			var req daemonRequest
+			if json.NewDecoder(conn).Decode(&req) != nil || !filepath.IsAbs(req.Cwd) {
+				return
+			}
+			_ = json.NewEncoder(conn).Encode(d.get(req.Cwd))
+		}()
+	}
+}
+
+// daemonSocket returns the path of the daemon's socket. It lives in a
+// directory only the user can enter: the daemon runs git, and with it
+// repository hooks, for whoever connects.
+func daemonSocket() string {
+	if s := os.Getenv("STATUSLINE_SOCKET"); s != "" {
+		return s
+	}
+	dir := cacheDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "daemon", "statusline.sock")
+}
+
+// runDaemon runs "statusline daemon" and returns the exit code.
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	idle := fs.Duration("idle", 30*time.Minute, "exit after this long without requests")
+	_ = fs.Parse(args)
+
+	// the daemon collects for every render, so it follows the same
+	// untracked, fsmonitor and timeout settings
+	cfg, err := loadConfig(configDir())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "statusline:", err)
+	}
+	cfg.applyVCS()
+
+	path := daemonSocket()
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "statusline: no cache directory for the daemon socket")
+		return 1
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		fmt.Fprintln(os.Stderr, "statusline: daemon already running on", path)
+		return 1
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		fmt.Fprintln(os.Stderr, "statusline:", err)
+		return 1
+	}
+	_ = os.Chmod(filepath.Dir(path), 0o700)
+	_ = os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "statusline:", err)
+		return 1
+	}
+
+	d := newDaemon(collect)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	d.run(l, *idle, sig)
+	return 0
+}
+
+// run serves l until a signal arrives or no request came for idle.
+func (d *daemon) run(l net.Listener, idle time.Duration, sig <-chan os.Signal) {
+	go func() {
+		tick := time.NewTicker(max(min(idle/10, time.Minute), 10*time.Millisecond))
+		defer tick.Stop()
+		for {
+			select {
+			case <-sig:
+			case <-tick.C:
+				if d.idle() < idle {
+					d.forget(daemonForget)
+					continue
+				}
+			}
+			l.Close()
+			return
+		}
+	}()
+	d.serve(l)
+	if d.w != nil {
+		d.w.Close()
+	}
+}
+
+// daemonCollect asks a running daemon for the state of the repository
+// containing cwd. It reports false when no daemon answers within
+// daemonTimeout or the render's budget.
+func daemonCollect(cwd string) (repoInfo, bool) {
+	path := daemonSocket()
+	if path == "" || os.Getenv("STATUSLINE_DAEMON") == "0" {
+		return repoInfo{}, false
+	}
+	deadline := time.Now().Add(daemonTimeout)
+	if !runDeadline.IsZero() && runDeadline.Before(deadline) {
+		deadline = runDeadline
+	}
+	conn, err := net.DialTimeout("unix", path, time.Until(deadline))
+	if err != nil {
+		return repoInfo{}, false
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(deadline)
+	if cwd, err = filepath.Abs(cwd); err != nil {
+		return repoInfo{}, false
+	}
+	if json.NewEncoder(conn).Encode(daemonRequest{Cwd: cwd}) != nil {
+		return repoInfo{}, false
+	}
+	var ri repoInfo
+	if json.NewDecoder(conn).Decode(&ri) != nil {
+		return repoInfo{}, false
+	}
+	debugf("state of %s from daemon at %s", cwd, path)
+	return ri, true
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWatcher struct {
+	roots []string
+	err   error // returned by Add
+}
+
+func (w *fakeWatcher) Add(root string) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.roots = append(w.roots, root)
+	return nil
+}
+
+func (w *fakeWatcher) Close() error { return nil }
+
+func testDaemon(ri repoInfo) (*daemon, *fakeWatcher, *int) {
+	var mu sync.Mutex
+	calls := 0
+	w := &fakeWatcher{}
+	d := newDaemon(func(string) repoInfo {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return ri
+	})
+	d.w = w
+	return d, w, &calls
+}
+
+func TestDaemonGet(t *testing.T) {
+	ri := repoInfo{Root: "/src/app", Branch: "main", IsRepo: true}
+	d, w, calls := testDaemon(ri)
+
+	assert.Equal(t, ri, d.get("/src/app/api"))
+	assert.Equal(t, []string{"/src/app"}, w.roots)
+	// collected before the watch was in place, so collected once more
+	d.get("/src/app/api")
+	assert.Equal(t, 2, *calls)
+
+	d.get("/src/app/api")
+	d.get("/src/app/api")
+	assert.Equal(t, 2, *calls)
+
+	d.changed("/src/app")
+	d.get("/src/app/api")
+	assert.Equal(t, 3, *calls)
+	d.get("/src/app/api")
+	assert.Equal(t, 3, *calls)
+
+	// other directories of a watched repository share the watch
+	d.get("/src/app/web")
+	d.get("/src/app/web")
+	assert.Equal(t, 4, *calls)
+	assert.Len(t, w.roots, 1)
+}
+
+func TestDaemonGetUnwatched(t *testing.T) {
+	d, w, calls := testDaemon(repoInfo{Project: "tmp"})
+	d.get("/tmp")
+	d.get("/tmp")
+	assert.Equal(t, 1, *calls)
+	assert.Empty(t, w.roots)
+
+	d.entries["/tmp"] = daemonEntry{ri: repoInfo{Project: "tmp"}, at: time.Now().Add(-daemonPoll), gen: -1}
+	d.get("/tmp")
+	assert.Equal(t, 2, *calls)
+}
+
+func TestDaemonGetTooLarge(t *testing.T) {
+	ri := repoInfo{Root: "/src/mono", Branch: "main", IsRepo: true}
+	d, w, calls := testDaemon(ri)
+	w.err = errors.New("too many directories to watch")
+
+	d.get("/src/mono")
+	d.get("/src/mono")
+	assert.Equal(t, 1, *calls, "polled")
+
+	// events of watches left over from the failed Add don't make the
+	// repository count as watched
+	d.changed("/src/mono")
+	d.entries["/src/mono"] = daemonEntry{ri: ri, at: time.Now().Add(-daemonPoll), gen: -1}
+	d.get("/src/mono")
+	assert.Equal(t, 2, *calls)
+
+	d.changed("/src/other")
+	assert.NotContains(t, d.gen, "/src/other")
+}
+
+func TestDaemonDropped(t *testing.T) {
+	ri := repoInfo{Root: "/src/app", Branch: "main", IsRepo: true}
+	d, _, calls := testDaemon(ri)
+	d.get("/src/app")
+	d.get("/src/app")
+	d.get("/src/app")
+	assert.Equal(t, 2, *calls)
+
+	// once the watches are gone, the state is collected again after a poll
+	d.dropped("/src/app")
+	d.changed("/src/app")
+	d.get("/src/app")
+	assert.Equal(t, 2, *calls)
+	e := d.entries["/src/app"]
+	e.at = time.Now().Add(-daemonPoll)
+	d.entries["/src/app"] = e
+	d.get("/src/app")
+	assert.Equal(t, 3, *calls)
+}
+
+func TestDaemonForget(t *testing.T) {
+	d, _, _ := testDaemon(repoInfo{Project: "tmp"})
+	d.get("/tmp")
+	d.get("/var")
+	e := d.entries["/var"]
+	e.used = time.Now().Add(-time.Hour)
+	d.entries["/var"] = e
+
+	d.forget(time.Minute)
+	assert.Contains(t, d.entries, "/tmp")
+	assert.NotContains(t, d.entries, "/var")
+}
+
+func TestDaemonClient(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "statusline.sock")
+	t.Setenv("STATUSLINE_SOCKET", sock)
+	t.Setenv("STATUSLINE_DAEMON", "")
+
+	_, ok := daemonCollect("/src/app")
+	assert.False(t, ok, "no daemon running")
+
+	ri := repoInfo{Root: "/src/app", Project: "app", Branch: "main", Ahead: 2, IsRepo: true}
+	d, _, _ := testDaemon(ri)
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		d.run(l, time.Hour, nil)
+		close(done)
+	}()
+	t.Cleanup(func() { l.Close(); <-done })
+
+	got, ok := daemonCollect("/src/app")
+	require.True(t, ok)
+	assert.Equal(t, ri, got)
+
+	setRunTimeout(t, runTimeout, time.Now().Add(-time.Millisecond))
+	_, ok = daemonCollect("/src/app")
+	assert.False(t, ok, "budget used up")
+
+	t.Setenv("STATUSLINE_DAEMON", "0")
+	setRunTimeout(t, runTimeout, time.Time{})
+	_, ok = daemonCollect("/src/app")
+	assert.False(t, ok)
+}
+
+func TestRunDaemonConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"untracked": "no", "fsmonitor": true, "timeout": "1s"}`), 0o644))
+	t.Setenv("STATUSLINE_CONFIG_DIR", dir)
+	t.Setenv("STATUSLINE_UNTRACKED", "")
+	t.Setenv("STATUSLINE_FSMONITOR", "")
+	t.Setenv("STATUSLINE_TIMEOUT", "")
+	setGitOptions(t, untrackedNormal, false)
+	setRunTimeout(t, 300*time.Millisecond, time.Time{})
+
+	// a running daemon makes runDaemon return once the config is applied
+	sock := filepath.Join(t.TempDir(), "statusline.sock")
+	t.Setenv("STATUSLINE_SOCKET", sock)
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer l.Close()
+
+	assert.Equal(t, 1, runDaemon(nil))
+	assert.Equal(t, untrackedNo, gitOptions.Untracked)
+	assert.True(t, gitOptions.FSMonitor)
+	assert.Equal(t, time.Second, runTimeout)
+}
+
+func TestDaemonIdleExit(t *testing.T) {
+	d, _, _ := testDaemon(repoInfo{})
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "s.sock"))
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		d.run(l, 50*time.Millisecond, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		l.Close()
+		t.Fatal("daemon did not exit when idle")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// debugLog receives diagnostics when debugging is on, and is nil otherwise.
+var debugLog *log.Logger
+
+// debugTarget tells where to log given STATUSLINE_DEBUG and the --debug
+// flag: "stderr", a file name, or "" when debugging is off. A boolean value
+// such as "1" or "false" switches logging to stderr on or off; anything else
+// names a file.
+func debugTarget(env string, flag bool) string {
+	if on, err := strconv.ParseBool(env); err == nil {
+		env = ""
+		if on {
+			env = "stderr"
+		}
+	}
+	if env == "" && flag {
+		env = "stderr"
+	}
+	return env
+}
+
+// startDebug turns on diagnostics. target "stderr" logs to stderr, anything
+// else names a file to append to, since Claude Code doesn't show the
+// statusline's stderr. The returned func closes the file.
+func startDebug(target string) (func(), error) {
+	var w io.Writer = os.Stderr
+	closer := func() {}
+	if target != "stderr" {
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return closer, err
+		}
+		w, closer = f, func() { f.Close() }
+	}
+	// several sessions may share the file
+	debugLog = log.New(w, "statusline["+strconv.Itoa(os.Getpid())+"] ", log.Ldate|log.Lmicroseconds)
+	return closer, nil
+}
+
+// debugf logs when debugging is on. Continuation lines are indented so a
+// command's stderr reads as part of its entry.
+func debugf(format string, args ...any) {
+	if debugLog == nil {
+		return
+	}
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	debugLog.Print(strings.ReplaceAll(msg, "\n", "\n\t"))
+}
+
+// debugCommand logs a finished command with its duration, exit code (-1 when
+// it did not start or was killed) and stderr.
+func debugCommand(cmd *exec.Cmd, d time.Duration, timedOut bool, stderr string) {
+	code := -1
+	if cmd.ProcessState != nil {
+		code = cmd.ProcessState.ExitCode()
+	}
+	line := fmt.Sprintf("exec %s in %s: %v exit %d", strings.Join(cmd.Args, " "), cmd.Dir, d.Round(time.Microsecond), code)
+	if timedOut {
+		line += " (timed out)"
+	}
+	if stderr != "" {
+		line += "\n" + stderr
+	}
+	debugf("%s", line)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestDebug logs to a file in a temporary directory for the rest of the
+// test and returns its path.
+func startTestDebug(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "debug.log")
+	stop, err := startDebug(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		stop()
+		debugLog = nil
+	})
+	return path
+}
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestDebugf(t *testing.T) {
+	debugf("dropped %d", 1) // off: no logger, no panic
+
+	path := startTestDebug(t)
+	debugf("first\nsecond\n")
+	log := readLog(t, path)
+	assert.Contains(t, log, "statusline[")
+	assert.Contains(t, log, "first\n\tsecond\n")
+	assert.NotContains(t, log, "dropped")
+}
+
+func TestDebugTarget(t *testing.T) {
+	for _, tt := range []struct {
+		env  string
+		flag bool
+		want string
+	}{
+		{"", false, ""},
+		{"0", false, ""},
+		{"false", false, ""},
+		{"1", false, "stderr"},
+		{"true", false, "stderr"},
+		{"stderr", false, "stderr"},
+		{"/tmp/statusline.log", false, "/tmp/statusline.log"},
+		{"", true, "stderr"},
+		{"0", true, "stderr"},
+		{"/tmp/statusline.log", true, "/tmp/statusline.log"},
+	} {
+		assert.Equal(t, tt.want, debugTarget(tt.env, tt.flag), "%q %v", tt.env, tt.flag)
+	}
+}
+
+func TestStartDebugError(t *testing.T) {
+	_, err := startDebug(filepath.Join(t.TempDir(), "missing", "debug.log"))
+	assert.Error(t, err)
+}
+
+func TestDebugCommand(t *testing.T) {
+	path := startTestDebug(t)
+	dir := t.TempDir()
+
+	_, err := runErr(dir, nil, "sh", "-c", "echo oops >&2; exit 3")
+	require.Error(t, err)
+	log := readLog(t, path)
+	assert.Contains(t, log, "exec sh -c echo oops >&2; exit 3 in "+dir+": ")
+	assert.Contains(t, log, "exit 3\n\toops\n")
+
+	setRunTimeout(t, 50*time.Millisecond, time.Time{})
+	_, err = runErr(dir, nil, "sleep", "1")
+	require.ErrorIs(t, err, errTimeout)
+	assert.Contains(t, readLog(t, path), "exit -1 (timed out)")
+}
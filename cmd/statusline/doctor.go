@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Levels of a doctor probe.
+const (
+	probeOK   = "ok"
+	probeWarn = "warn"
+	probeFail = "fail"
+)
+
+// doctorTimeout lets probes run to completion so their latency can be
+// compared with the timeout a render would give them.
+const doctorTimeout = 10 * time.Second
+
+// minGitVersion is the first git with `status --porcelain=2`.
+var minGitVersion = [2]int{2, 11}
+
+// probe is one line of the doctor report.
+type probe struct {
+	Name   string
+	Level  string
+	Detail string
+	Fix    string
+	Took   time.Duration // 0 when the probe ran no command
+	Render bool          // a render runs the same commands, within the budget
+}
+
+// doctorEnv is what the probes check.
+type doctorEnv struct {
+	Dir       string // directory checked, cwd by default
+	ConfigDir string
+	Config    config
+	ConfigErr error
+	Timeout   time.Duration // of each VCS command in a render
+	Budget    time.Duration // of all of them, 0 when unbounded
+}
+
+// runDoctor implements `statusline doctor [dir]`. It returns 1 when a
+// probe failed.
+func runDoctor(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	dir := fs.Arg(0)
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			fmt.Fprintln(os.Stderr, "statusline:", err)
+			return 2
+		}
+	}
+	env := doctorEnv{Dir: dir, ConfigDir: configDir()}
+	env.Config, env.ConfigErr = loadConfig(env.ConfigDir)
+	env.Config.applyVCS()
+	env.Timeout, runTimeout = runTimeout, doctorTimeout
+	env.Budget = time.Duration(env.Config.Budget)
+
+	fmt.Fprintf(w, "statusline %s, checking %s\n\n", version, dir)
+	probes := doctor(env)
+	writeReport(w, probes)
+	for _, p := range probes {
+		if p.Level == probeFail {
+			return 1
+		}
+	}
+	return 0
+}
+
+// doctor runs the probes in order. Repository probes are left out outside a
+// repository and those about upstreams for backends other than git.
+func doctor(env doctorEnv) []probe {
+	probes := []probe{gitProbe(env), configProbe(env), colorProbe(env), glyphProbe(env)}
+
+	start := time.Now()
+	b, root := detectVCS(env.Dir)
+	p := probe{Name: "repository", Level: probeOK, Took: time.Since(start), Render: true}
+	if b == nil {
+		p.Detail = "not in a repository; only the directory name is shown"
+		probes = append(probes, p.timed(env))
+	} else {
+		p.Detail = fmt.Sprintf("%s repository at %s", b.Name(), root)
+		probes = append(probes, p.timed(env), statusProbe(env, b, root))
+		if b.Name() == "git" {
+			up, ok := upstreamProbe(env, root)
+			probes = append(probes, up)
+			if ok {
+				probes = append(probes, fetchProbe(env, root))
+			}
+		}
+	}
+	if env.Budget > 0 {
+		probes = append(probes, budgetProbe(env, probes))
+	}
+	return append(probes, daemonProbe(env))
+}
+
+// timed warns when the probe took longer than a render gives a command: the
+// timeout, or the budget when that is shorter.
+func (p probe) timed(env doctorEnv) probe {
+	limit, name, key := env.Timeout, "timeout", "STATUSLINE_TIMEOUT"
+	if env.Budget > 0 && env.Budget < limit {
+		limit, name, key = env.Budget, "budget", "STATUSLINE_BUDGET"
+	}
+	if p.Took <= limit || p.Level == probeFail {
+		return p
+	}
+	p.Level = probeWarn
+	p.Detail += fmt.Sprintf("; slower than the %v %s", limit, name)
+	if p.Fix == "" {
+		p.Fix = fmt.Sprintf(`raise %q in config.json (%s) above %v, or run "statusline daemon"`, name, key, p.Took.Round(10*time.Millisecond))
+	}
+	return p
+}
+
+// budgetProbe compares the total time of the probes a render repeats with
+// the budget.
+func budgetProbe(env doctorEnv, probes []probe) probe {
+	var took time.Duration
+	var names []string
+	for _, p := range probes {
+		if p.Render {
+			took += p.Took
+			names = append(names, p.Name)
+		}
+	}
+	p := probe{Name: "budget", Level: probeOK, Took: took}
+	p.Detail = fmt.Sprintf("%s within the %v budget", strings.Join(names, ", "), env.Budget)
+	if took > env.Budget {
+		p.Level = probeWarn
+		p.Detail = fmt.Sprintf("%s together slower than the %v budget", strings.Join(names, ", "), env.Budget)
+		p.Fix = fmt.Sprintf(`raise "budget" in config.json (STATUSLINE_BUDGET) above %v, or run "statusline daemon"`, took.Round(10*time.Millisecond))
+	}
+	return p
+}
+
+func gitProbe(env doctorEnv) probe {
+	p := probe{Name: "git", Level: probeOK}
+	start := time.Now()
+	out, err := gitErr(env.Dir, "--version")
+	p.Took = time.Since(start)
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return probe{Name: "git", Level: probeFail, Detail: "git is not on PATH", Fix: "install git and make sure PATH includes it where Claude Code runs"}
+	case err != nil:
+		return probe{Name: "git", Level: probeFail, Detail: err.Error(), Took: p.Took}
+	}
+	p.Detail = out
+	if v, ok := parseGitVersion(out); ok && (v[0] < minGitVersion[0] || v[0] == minGitVersion[0] && v[1] < minGitVersion[1]) {
+		p.Level = probeFail
+		p.Fix = fmt.Sprintf("upgrade to git %d.%d or later for `git status --porcelain=2`", minGitVersion[0], minGitVersion[1])
+	}
+	return p.timed(env)
+}
+
+// parseGitVersion reads the major and minor version from `git --version`,
+// e.g. "git version 2.39.3 (Apple Git-146)".
+func parseGitVersion(s string) ([2]int, bool) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return [2]int{}, false
+	}
+	parts := strings.SplitN(fields[2], ".", 3)
+	if len(parts) < 2 {
+		return [2]int{}, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	return [2]int{major, minor}, err1 == nil && err2 == nil
+}
+
+func configProbe(env doctorEnv) probe {
+	p := probe{Name: "config", Level: probeOK}
+	path := filepath.Join(env.ConfigDir, "config.json")
+	if _, err := os.Stat(path); err == nil {
+		p.Detail = path
+	} else {
+		p.Detail = "no " + path + ", using the defaults"
+	}
+	cfg := env.Config
+	var problems []string
+	if env.ConfigErr != nil {
+		problems = append(problems, env.ConfigErr.Error())
+	}
+	if _, err := loadTheme(env.ConfigDir, cfg.Theme); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := lookupIcons(cfg.Icons); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if !slices.Contains([]string{"", modePlain, modePowerline, modeRounded}, cfg.Mode) {
+		problems = append(problems, fmt.Sprintf("unknown mode %q (want plain, powerline or rounded)", cfg.Mode))
+	}
+	if !slices.Contains([]string{"", untrackedNormal, untrackedNo, untrackedAuto}, cfg.Untracked) {
+		problems = append(problems, fmt.Sprintf("unknown untracked mode %q (want normal, no or auto)", cfg.Untracked))
+	}
+	if !slices.Contains([]string{"", subProjectPath, subProjectName, subProjectOff}, cfg.SubProject) {
+		problems = append(problems, fmt.Sprintf("unknown subproject %q (want path, name or off)", cfg.SubProject))
+	}
+	for _, l := range cfg.Lines {
+		for _, name := range append(l.Segments[:len(l.Segments):len(l.Segments)], l.Right...) {
+			_, builtin := segmentFuncs[name]
+			_, plugin := cfg.Plugins[name]
+			if !builtin && !plugin {
+				problems = append(problems, fmt.Sprintf("unknown segment %q in lines", name))
+			}
+		}
+	}
+	for name, pl := range cfg.Plugins {
+		if pl.Command == "" && pl.Expr == "" {
+			problems = append(problems, fmt.Sprintf("plugin %q has neither command nor expr", name))
+		}
+	}
+	if len(problems) > 0 {
+		p.Level = probeFail
+		p.Detail += ": " + strings.Join(problems, "; ")
+		p.Fix = "the line falls back to defaults for these; see the README for valid values"
+	}
+	return p
+}
+
+// colorProbe reports whether the line is colored and whether the theme
+// needs more colors than the terminal announces. Claude Code reads the line
+// through a pipe, so the terminal itself can't be asked.
+func colorProbe(env doctorEnv) probe {
+	p := probe{Name: "color", Level: probeOK}
+	if !resolveColor(colorAuto) {
+		p.Level = probeWarn
+		p.Detail = "off because of NO_COLOR, STATUSLINE_NO_COLOR=1, CLICOLOR=0 or FORCE_COLOR=0"
+		p.Fix = "unset the variable where Claude Code runs, or run statusline --color always"
+		return p
+	}
+	p.Detail = "on"
+	th, _ := loadTheme(env.ConfigDir, env.Config.Theme)
+	b, _ := json.Marshal(th)
+	ct := strings.ToLower(os.Getenv("COLORTERM"))
+	if bytes.Contains(b, []byte(`"#`)) {
+		if ct != "truecolor" && ct != "24bit" {
+			p.Level = probeWarn
+			p.Detail += ", but the theme uses 24-bit colors and COLORTERM doesn't announce them"
+			p.Fix = "use a terminal with truecolor support, or a 256-color theme such as default or gruvbox"
+		} else {
+			p.Detail += ", 24-bit"
+		}
+	}
+	return p
+}
+
+// glyphProbe shows the glyphs the configuration needs, since only the user
+// can see whether the font has them.
+func glyphProbe(env doctorEnv) probe {
+	cfg := env.Config
+	icons, _ := lookupIcons(cfg.Icons)
+	p := probe{Name: "glyphs", Level: probeOK}
+	var need []string
+	if cfg.Icons != "ascii" {
+		for _, g := range []string{icons.Branch, icons.Ahead, icons.Behind, icons.Model, icons.Kube} {
+			if g != "" {
+				need = append(need, g)
+			}
+		}
+	}
+	switch cfg.Mode {
+	case modePowerline:
+		need = append(need, plSeparator, plRightSeparator)
+	case modeRounded:
+		need = append(need, roundLeftCap, roundSeparator)
+	}
+	if len(need) == 0 {
+		p.Detail = "ascii icons need no special font"
+		return p
+	}
+	p.Detail = strings.Join(need, " ")
+	if cfg.Icons == "nerd" || cfg.Mode == modePowerline || cfg.Mode == modeRounded {
+		p.Detail += "  (boxes or question marks mean the font lacks them)"
+		p.Fix = `use a Nerd Font (https://www.nerdfonts.com) in the terminal, or set "icons": "unicode" and "mode": "plain"`
+	}
+	return p
+}
+
+// statusProbe times collecting the state without the cache, as a render
+// does after every change.
+func statusProbe(env doctorEnv, b vcs, root string) probe {
+	p := probe{Name: "status", Level: probeOK, Render: true}
+	start := time.Now()
+	if b.Name() == "git" {
+		_, unknown, err := gitStatus(root)
+		p.Took = time.Since(start)
+		if err != nil {
+			return probe{Name: "status", Level: probeFail, Detail: err.Error(), Took: p.Took}
+		}
+		p.Detail = "git status, untracked files " + gitOptions.Untracked
+		if unknown {
+			p.Detail += " (skipped)"
+		}
+		if p.Took > env.Timeout {
+			p.Fix = `set "untracked": "auto" and "fsmonitor": true in config.json, or run "statusline daemon"`
+		}
+		return p.timed(env)
+	}
+	b.Collect(root)
+	p.Took = time.Since(start)
+	p.Detail = b.Name() + " state"
+	return p.timed(env)
+}
+
+// upstreamProbe reports the upstream of the current branch, and whether
+// there is one to fetch from.
+func upstreamProbe(env doctorEnv, root string) (probe, bool) {
+	p := probe{Name: "upstream", Level: probeOK}
+	start := time.Now()
+	up, err := gitErr(root, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
+	p.Took = time.Since(start)
+	if err == nil && up != "" {
+		p.Detail = up
+		return p.timed(env), true
+	}
+	branch := git(root, "rev-parse", "--abbrev-ref", "HEAD")
+	if branch == "" || branch == "HEAD" {
+		p.Detail = "detached HEAD, no upstream"
+		return p, false
+	}
+	p.Level = probeWarn
+	p.Detail = branch + " has no upstream, so ahead/behind counts are missing"
+	remotes := strings.Fields(git(root, "remote"))
+	switch {
+	case len(remotes) == 0:
+		p.Fix = "add a remote and push the branch to it: git remote add origin <url> && git push -u origin " + branch
+	case slices.Contains(remotes, "origin"):
+		p.Fix = "git branch --set-upstream-to=origin/" + branch
+	default:
+		p.Fix = "git branch --set-upstream-to=" + remotes[0] + "/" + branch
+	}
+	return p, false
+}
+
+// fetchProbe checks that the upstream remote can be reached without a
+// prompt, as STATUSLINE_FETCH=1 needs. Prompts for passwords and SSH host
+// keys are turned into failures.
+func fetchProbe(env doctorEnv, root string) probe {
+	p := probe{Name: "fetch", Level: probeOK}
+	remote := git(root, "config", "branch."+git(root, "rev-parse", "--abbrev-ref", "HEAD")+".remote")
+	if remote == "" || remote == "." {
+		p.Detail = "upstream is a local branch"
+		return p
+	}
+	start := time.Now()
+	_, err := runErr(root, []string{
+		"GIT_TERMINAL_PROMPT=0",
+		"GCM_INTERACTIVE=never",
+		"GIT_SSH_COMMAND=ssh -o BatchMode=yes",
+	}, "git", "ls-remote", "--exit-code", "--heads", remote)
+	p.Took = time.Since(start)
+	fetching := os.Getenv("STATUSLINE_FETCH") == "1"
+	p.Render = fetching
+	if err != nil {
+		p.Level = probeWarn
+		if fetching {
+			p.Level = probeFail
+		}
+		p.Detail = remote + " needs credentials that can't be given without a prompt: " + err.Error()
+		p.Fix = "load the SSH key into ssh-agent, or set up a credential helper (git config credential.helper)"
+		return p
+	}
+	p.Detail = remote + " is reachable without a prompt"
+	if !fetching {
+		p.Detail += " (STATUSLINE_FETCH is off)"
+		return p
+	}
+	if p.Took > env.Timeout {
+		p.Level = probeWarn
+		p.Detail += fmt.Sprintf("; slower than the %v timeout, so fetches never finish", env.Timeout)
+		p.Fix = `raise "timeout" in config.json, or leave STATUSLINE_FETCH off`
+	}
+	return p
+}
+
+func daemonProbe(env doctorEnv) probe {
+	p := probe{Name: "daemon", Level: probeOK}
+	start := time.Now()
+	_, ok := daemonCollect(env.Dir)
+	p.Took = time.Since(start)
+	if ok {
+		p.Detail = "answering at " + daemonSocket()
+		return p
+	}
+	p.Detail = "not running; every render runs the VCS commands"
+	p.Took = 0
+	return p
+}
+
+// writeReport prints one line per probe, with the fix indented below.
+func writeReport(w io.Writer, probes []probe) {
+	for _, p := range probes {
+		line := fmt.Sprintf("%-5s %-11s %s", p.Level, p.Name, p.Detail)
+		if p.Took > 0 {
+			line += fmt.Sprintf(" (%v)", p.Took.Round(100*time.Microsecond))
+		}
+		fmt.Fprintln(w, line)
+		if p.Fix != "" {
+			fmt.Fprintf(w, "%17s fix: %s\n", "", p.Fix)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDoctorEnv(t *testing.T, dir string) doctorEnv {
+	t.Helper()
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	t.Setenv("STATUSLINE_DAEMON", "0")
+	t.Setenv("STATUSLINE_FETCH", "")
+	setRunTimeout(t, doctorTimeout, time.Time{})
+	return doctorEnv{Dir: dir, ConfigDir: t.TempDir(), Config: config{Markers: defaultMarkers}, Timeout: time.Minute}
+}
+
+func probeNamed(t *testing.T, probes []probe, name string) probe {
+	t.Helper()
+	for _, p := range probes {
+		if p.Name == name {
+			return p
+		}
+	}
+	require.Failf(t, "missing probe", "no %s probe in %v", name, probes)
+	return probe{}
+}
+
+func TestParseGitVersion(t *testing.T) {
+	v, ok := parseGitVersion("git version 2.39.3 (Apple Git-146)")
+	assert.True(t, ok)
+	assert.Equal(t, [2]int{2, 39}, v)
+
+	v, ok = parseGitVersion("git version 2.45.1.windows.1")
+	assert.True(t, ok)
+	assert.Equal(t, [2]int{2, 45}, v)
+
+	_, ok = parseGitVersion("git")
+	assert.False(t, ok)
+}
+
+func TestDoctorRepository(t *testing.T) {
+	root := testRepo(t)
+	probes := doctor(testDoctorEnv(t, root))
+
+	for _, name := range []string{"git", "config", "color", "glyphs", "repository", "status"} {
+		assert.Equal(t, probeOK, probeNamed(t, probes, name).Level, name)
+	}
+	assert.Contains(t, probeNamed(t, probes, "repository").Detail, "git repository at "+root)
+
+	up := probeNamed(t, probes, "upstream")
+	assert.Equal(t, probeWarn, up.Level)
+	assert.Equal(t, "add a remote and push the branch to it: git remote add origin <url> && git push -u origin main", up.Fix)
+	for _, p := range probes {
+		assert.NotEqual(t, "fetch", p.Name, "no upstream to fetch")
+		assert.NotEqual(t, "budget", p.Name, "no budget set")
+	}
+}
+
+func TestUpstreamProbe(t *testing.T) {
+	root := testRepo(t)
+	env := testDoctorEnv(t, root)
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = root
+		require.NoError(t, cmd.Run(), args)
+	}
+
+	run("remote", "add", "fork", t.TempDir())
+	up, ok := upstreamProbe(env, root)
+	assert.False(t, ok)
+	assert.Equal(t, "git branch --set-upstream-to=fork/main", up.Fix)
+
+	run("remote", "add", "origin", t.TempDir())
+	up, _ = upstreamProbe(env, root)
+	assert.Equal(t, "git branch --set-upstream-to=origin/main", up.Fix)
+
+	// a detached HEAD has no upstream, and no remote to fetch from
+	run("commit", "-q", "--allow-empty", "-m", "second")
+	run("checkout", "-q", "HEAD~1")
+	up, ok = upstreamProbe(env, root)
+	assert.False(t, ok)
+	assert.Equal(t, probeOK, up.Level)
+	assert.Equal(t, "detached HEAD, no upstream", up.Detail)
+	for _, p := range doctor(env) {
+		assert.NotEqual(t, "fetch", p.Name)
+	}
+}
+
+func TestDoctorOutsideRepository(t *testing.T) {
+	probes := doctor(testDoctorEnv(t, t.TempDir()))
+	assert.Contains(t, probeNamed(t, probes, "repository").Detail, "not in a repository")
+	for _, p := range probes {
+		assert.NotEqual(t, "status", p.Name)
+	}
+}
+
+func TestDoctorFetch(t *testing.T) {
+	root := testRepo(t)
+	remote := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "--bare", remote},
+		{"remote", "add", "origin", remote},
+		{"push", "-q", "-u", "origin", "main"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		require.NoError(t, cmd.Run(), args)
+	}
+
+	env := testDoctorEnv(t, root)
+	probes := doctor(env)
+	assert.Equal(t, "origin/main", probeNamed(t, probes, "upstream").Detail)
+	fetch := probeNamed(t, probes, "fetch")
+	assert.Equal(t, probeOK, fetch.Level)
+	assert.Contains(t, fetch.Detail, "origin is reachable without a prompt")
+
+	require.NoError(t, os.RemoveAll(remote))
+	t.Setenv("STATUSLINE_FETCH", "1")
+	fetch = fetchProbe(env, root)
+	assert.Equal(t, probeFail, fetch.Level)
+	assert.Contains(t, fetch.Fix, "credential helper")
+}
+
+func TestDoctorSlowProbe(t *testing.T) {
+	env := testDoctorEnv(t, testRepo(t))
+	env.Timeout = time.Nanosecond
+	st := statusProbe(env, gitBackend{}, env.Dir)
+	assert.Equal(t, probeWarn, st.Level)
+	assert.Contains(t, st.Detail, "slower than the 1ns timeout")
+	assert.Contains(t, st.Fix, `"untracked": "auto"`)
+}
+
+func TestDoctorBudget(t *testing.T) {
+	env := testDoctorEnv(t, testRepo(t))
+	env.Budget = time.Minute
+	budget := probeNamed(t, doctor(env), "budget")
+	assert.Equal(t, probeOK, budget.Level)
+	assert.Equal(t, "repository, status within the 1m0s budget", budget.Detail)
+
+	budget = budgetProbe(env, []probe{
+		{Name: "repository", Took: 40 * time.Second, Render: true},
+		{Name: "upstream", Took: time.Hour},
+		{Name: "status", Took: 30 * time.Second, Render: true},
+	})
+	assert.Equal(t, probeWarn, budget.Level)
+	assert.Equal(t, "repository, status together slower than the 1m0s budget", budget.Detail)
+	assert.Equal(t, `raise "budget" in config.json (STATUSLINE_BUDGET) above 1m10s, or run "statusline daemon"`, budget.Fix)
+
+	// a budget shorter than the timeout bounds every command
+	env.Budget = time.Nanosecond
+	p := probe{Name: "git", Level: probeOK, Took: time.Millisecond}.timed(env)
+	assert.Equal(t, probeWarn, p.Level)
+	assert.Contains(t, p.Detail, "slower than the 1ns budget")
+	assert.Contains(t, p.Fix, `raise "budget" in config.json (STATUSLINE_BUDGET)`)
+}
+
+func TestGitProbeMissing(t *testing.T) {
+	env := testDoctorEnv(t, t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	p := gitProbe(env)
+	assert.Equal(t, probeFail, p.Level)
+	assert.Equal(t, "git is not on PATH", p.Detail)
+}
+
+func TestConfigProbe(t *testing.T) {
+	env := testDoctorEnv(t, t.TempDir())
+	p := configProbe(env)
+	assert.Equal(t, probeOK, p.Level)
+	assert.Contains(t, p.Detail, "using the defaults")
+
+	env.Config = config{
+		Theme:     "nope",
+		Mode:      "fancy",
+		Untracked: "some",
+		Lines:     []lineLayout{{Segments: []string{"project", "weather"}, Right: []string{"clock"}}},
+		Plugins:   map[string]plugin{"empty": {}},
+	}
+	env.ConfigErr = errors.New("invalid character")
+	p = configProbe(env)
+	assert.Equal(t, probeFail, p.Level)
+	for _, s := range []string{`invalid character`, `unknown theme "nope"`, `unknown mode "fancy"`, `unknown untracked mode "some"`, `unknown segment "weather"`, `plugin "empty"`} {
+		assert.Contains(t, p.Detail, s)
+	}
+	assert.NotContains(t, p.Detail, `"clock"`)
+}
+
+func TestColorProbe(t *testing.T) {
+	env := testDoctorEnv(t, t.TempDir())
+	t.Setenv("FORCE_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+	t.Setenv("CLICOLOR", "")
+	t.Setenv("STATUSLINE_NO_COLOR", "")
+	t.Setenv("NO_COLOR", "1")
+	assert.Equal(t, probeWarn, colorProbe(env).Level)
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("COLORTERM", "")
+	assert.Equal(t, probeOK, colorProbe(env).Level)
+
+	env.Config.Theme = "catppuccin"
+	assert.Equal(t, probeWarn, colorProbe(env).Level)
+	t.Setenv("COLORTERM", "truecolor")
+	assert.Equal(t, "on, 24-bit", colorProbe(env).Detail)
+}
+
+func TestGlyphProbe(t *testing.T) {
+	env := testDoctorEnv(t, t.TempDir())
+	env.Config.Icons = "ascii"
+	assert.Equal(t, "ascii icons need no special font", glyphProbe(env).Detail)
+
+	env.Config.Mode = modePowerline
+	p := glyphProbe(env)
+	assert.Contains(t, p.Detail, plSeparator)
+	assert.Contains(t, p.Fix, "Nerd Font")
+}
+
+func TestWriteReport(t *testing.T) {
+	var b bytes.Buffer
+	writeReport(&b, []probe{
+		{Name: "git", Level: probeOK, Detail: "git version 2.45.0", Took: 1234 * time.Microsecond},
+		{Name: "upstream", Level: probeWarn, Detail: "main has no upstream", Fix: "git branch --set-upstream-to=origin/main"},
+	})
+	assert.Equal(t, "ok    git         git version 2.45.0 (1.2ms)\n"+
+		"warn  upstream    main has no upstream\n"+
+		"                  fix: git branch --set-upstream-to=origin/main\n", b.String())
+}
+
+func TestRunDoctor(t *testing.T) {
+	t.Setenv("STATUSLINE_CONFIG_DIR", t.TempDir())
+	testDoctorEnv(t, "")
+	var b bytes.Buffer
+	assert.Equal(t, 0, runDoctor([]string{t.TempDir()}, &b))
+	assert.Contains(t, b.String(), "ok    git ")
+
+	dir := t.TempDir()
+	t.Setenv("STATUSLINE_CONFIG_DIR", dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"mode": "fancy"}`), 0o644))
+	assert.Equal(t, 1, runDoctor([]string{t.TempDir()}, &b))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// containerMarkers are files whose presence means the process runs in a
+// container. Replaced in tests.
+var containerMarkers = []string{"/.dockerenv", "/run/.containerenv"}
+
+// environments describes the environments the session runs in, e.g.
+// "venv:api", "conda:ml", "nix:pure", "direnv:api" and "container". Claude Code
+// passes its own environment on to the statusline, so these are the ones the
+// agent's commands run in as well.
+func environments() []string {
+	var envs []string
+	if v := os.Getenv("VIRTUAL_ENV"); v != "" {
+		envs = append(envs, "venv:"+venvName(v))
+	}
+	if c := os.Getenv("CONDA_DEFAULT_ENV"); c != "" {
+		envs = append(envs, "conda:"+c)
+	}
+	if n := os.Getenv("IN_NIX_SHELL"); n != "" {
+		if n == "1" {
+			n = "shell"
+		}
+		envs = append(envs, "nix:"+n)
+	}
+	if d := os.Getenv("DIRENV_DIR"); d != "" {
+		// direnv prefixes the directory with "-"
+		envs = append(envs, "direnv:"+filepath.Base(strings.TrimPrefix(d, "-")))
+	}
+	if inContainer() {
+		envs = append(envs, "container")
+	}
+	return envs
+}
+
+// venvName prefers the prompt the virtualenv was created with, then its
+// directory name, then for the usual .venv/venv the project directory name.
+func venvName(dir string) string {
+	if p := strings.Trim(strings.TrimSpace(os.Getenv("VIRTUAL_ENV_PROMPT")), "()"); p != "" {
+		return p
+	}
+	name := filepath.Base(dir)
+	if name == ".venv" || name == "venv" {
+		return filepath.Base(filepath.Dir(dir))
+	}
+	return name
+}
+
+// inContainer reports whether we run in a dev container, Codespace or any
+// other container.
+func inContainer() bool {
+	for _, k := range []string{"REMOTE_CONTAINERS", "CODESPACES", "DEVCONTAINER"} {
+		if os.Getenv(k) == "true" {
+			return true
+		}
+	}
+	for _, m := range containerMarkers {
+		if _, err := os.Stat(m); err == nil {
+			return true
+		}
+	}
+	return false
+}
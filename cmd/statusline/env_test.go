@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironments(t *testing.T) {
+	old := containerMarkers
+	t.Cleanup(func() { containerMarkers = old })
+	containerMarkers = []string{filepath.Join(t.TempDir(), ".dockerenv")}
+
+	vars := []string{"VIRTUAL_ENV", "VIRTUAL_ENV_PROMPT", "CONDA_DEFAULT_ENV", "IN_NIX_SHELL", "DIRENV_DIR",
+		"REMOTE_CONTAINERS", "CODESPACES", "DEVCONTAINER"}
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected []string
+	}{
+		{"none", nil, nil},
+		{"virtualenv", map[string]string{"VIRTUAL_ENV": "/home/u/.virtualenvs/tools"}, []string{"venv:tools"}},
+		{"project .venv", map[string]string{"VIRTUAL_ENV": "/src/api/.venv"}, []string{"venv:api"}},
+		{"venv prompt", map[string]string{"VIRTUAL_ENV": "/src/api/.venv", "VIRTUAL_ENV_PROMPT": "(api-dev) "}, []string{"venv:api-dev"}},
+		{"conda", map[string]string{"CONDA_DEFAULT_ENV": "ml"}, []string{"conda:ml"}},
+		{"nix pure", map[string]string{"IN_NIX_SHELL": "pure"}, []string{"nix:pure"}},
+		{"nix develop", map[string]string{"IN_NIX_SHELL": "1"}, []string{"nix:shell"}},
+		{"direnv", map[string]string{"DIRENV_DIR": "-/src/api"}, []string{"direnv:api"}},
+		{"devcontainer", map[string]string{"REMOTE_CONTAINERS": "true"}, []string{"container"}},
+		{"several", map[string]string{"CONDA_DEFAULT_ENV": "base", "DIRENV_DIR": "-/src/api", "CODESPACES": "true"},
+			[]string{"conda:base", "direnv:api", "container"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range vars {
+				t.Setenv(k, tt.env[k])
+			}
+			assert.Equal(t, tt.expected, environments())
+		})
+	}
+}
+
+func TestEnvSegment(t *testing.T) {
+	opts := renderOptions{Icons: iconSets["unicode"], Lines: parseLines("project,env")}
+	assert.Equal(t, "statusline", render(repoInfo{Project: "statusline"}, input{}, opts))
+	assert.Equal(t, "statusline ⬢ conda:ml container", render(repoInfo{Project: "statusline", Envs: []string{"conda:ml", "container"}}, input{}, opts))
+}
@@ -0,0 +1,62 @@
+package main
+
+import "strings"
+
+// hgTemplate prints the working-copy parent as tab-separated fields: phase,
+// active bookmark and branch. The possibly empty bookmark sits in the middle
+// so that trimming the output keeps the field count.
+const hgTemplate = `{phase}\t{activebookmark}\t{branch}\n`
+
+// hgBackend reads repository state from Mercurial or Sapling, whose CLIs
+// share the commands used here.
+type hgBackend struct {
+	name   string // "hg" or "sl"
+	cmd    string // executable
+	marker string // directory marking the repository root
+}
+
+func (b hgBackend) Name() string { return b.name }
+
+func (b hgBackend) Root(dir string) (string, bool) {
+	return findUp(dir, b.marker)
+}
+
+func (b hgBackend) Collect(root string) repoInfo {
+	// plain mode ignores user aliases, defaults and color settings
+	env := []string{"HGPLAIN=1", "SLPLAIN=1"}
+	var ri repoInfo
+	log := runEnv(root, env, b.cmd, "log", "-r", ".", "-T", hgTemplate)
+	ri.Branch, ri.Phase = parseHgLog(log)
+	status := runEnv(root, env, b.cmd, "status", "-mardu")
+	ri.HasTracked, ri.HasUntracked = parseHgStatus(status)
+	if ri.Branch == "" {
+		ri.Branch = "no-branch"
+	}
+	return ri
+}
+
+// parseHgLog returns the active bookmark, or the branch when no bookmark is
+// active, and the phase.
+func parseHgLog(s string) (branch, phase string) {
+	f := strings.Split(strings.TrimRight(s, "\r\n"), "\t")
+	if len(f) != 3 {
+		return "", ""
+	}
+	branch = f[1]
+	if branch == "" {
+		branch = f[2]
+	}
+	return branch, f[0]
+}
+
+func parseHgStatus(s string) (hasTracked, hasUntracked bool) {
+	for ln := range strings.SplitSeq(s, "\n") {
+		switch {
+		case strings.HasPrefix(ln, "? "):
+			hasUntracked = true
+		case len(ln) > 2 && ln[1] == ' ':
+			hasTracked = true
+		}
+	}
+	return
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHgLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		branch string
+		phase  string
+	}{
+		{"active bookmark", "draft\tfeature\tdefault\n", "feature", "draft"},
+		{"branch only", "public\t\tstable", "stable", "public"},
+		{"empty", "", "", ""},
+		{"malformed", "default", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			branch, phase := parseHgLog(tt.input)
+			assert.Equal(t, tt.branch, branch)
+			assert.Equal(t, tt.phase, phase)
+		})
+	}
+}
+
+func TestParseHgStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		tracked   bool
+		untracked bool
+	}{
+		{"clean", "", false, false},
+		{"modified", "M main.go\nA new.go", true, false},
+		{"missing and removed", "! gone.go\nR old.go", true, false},
+		{"untracked", "? notes.txt", false, true},
+		{"mixed", "M main.go\n? notes.txt\n", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracked, untracked := parseHgStatus(tt.input)
+			assert.Equal(t, tt.tracked, tracked)
+			assert.Equal(t, tt.untracked, untracked)
+		})
+	}
+}
+
+func TestDetectVCSHg(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".hg"), 0o755))
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+
+	b, root := detectVCS(sub)
+	require.NotNil(t, b)
+	assert.Equal(t, "hg", b.Name())
+	assert.Equal(t, dir, root)
+
+	require.NoError(t, os.Mkdir(filepath.Join(sub, ".sl"), 0o755))
+	b, root = detectVCS(sub)
+	require.NotNil(t, b)
+	assert.Equal(t, "sl", b.Name(), "innermost repository wins")
+	assert.Equal(t, sub, root)
+}
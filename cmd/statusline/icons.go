@@ -0,0 +1,93 @@
+package main
+
+import "fmt"
+
+// iconSet holds the glyph of every segment. An empty glyph is left out.
+type iconSet struct {
+	Nested   string // between repository and sub-project name
+	Outside  string // agent left the project directory
+	Branch   string
+	Tag      string
+	Stash    string
+	Conflict string
+	Model    string
+	Env      string
+	Kube     string
+	Cloud    string
+	Ahead    string
+	Behind   string
+	Go       string
+	Node     string
+	Python   string
+	Rust     string
+}
+
+var iconSets = map[string]iconSet{
+	// nerd needs a Nerd Font (https://www.nerdfonts.com).
+	"nerd": {
+		Nested:   "\uf054",     // nf-fa-chevron_right
+		Outside:  "\uf08b",     // nf-fa-sign_out
+		Branch:   "\ue0a0",     // nf-pl-branch
+		Tag:      "\uf02b",     // nf-fa-tag
+		Stash:    "\uf01c",     // nf-fa-inbox
+		Conflict: "\uf071",     // nf-fa-warning
+		Model:    "\U000f06a9", // nf-md-robot
+		Env:      "\uf1b2",     // nf-fa-cube
+		Kube:     "\U000f10fe", // nf-md-kubernetes
+		Cloud:    "\uf0c2",     // nf-fa-cloud
+		Ahead:    "\uf062",     // nf-fa-arrow_up
+		Behind:   "\uf063",     // nf-fa-arrow_down
+		Go:       "\ue627",     // nf-seti-go
+		Node:     "\ue718",     // nf-dev-nodejs_small
+		Python:   "\ue73c",     // nf-dev-python
+		Rust:     "\ue7a8",     // nf-dev-rust
+	},
+	"unicode": {
+		Nested:   "›",
+		Outside:  "⚠",
+		Branch:   "⎇",
+		Tag:      "⚑",
+		Stash:    "≡",
+		Conflict: "✘",
+		Model:    "◆",
+		Env:      "⬢",
+		Kube:     "⎈",
+		Cloud:    "☁",
+		Ahead:    "↑",
+		Behind:   "↓",
+		Go:       "go",
+		Node:     "node",
+		Python:   "py",
+		Rust:     "rs",
+	},
+	"ascii": {
+		Nested:   ">",
+		Outside:  "outside:",
+		Branch:   "br",
+		Tag:      "tag:",
+		Stash:    "$",
+		Conflict: "!",
+		Model:    "model:",
+		Env:      "env:",
+		Kube:     "k8s:",
+		Cloud:    "cloud:",
+		Ahead:    "^",
+		Behind:   "v",
+		Go:       "go",
+		Node:     "node",
+		Python:   "py",
+		Rust:     "rs",
+	},
+}
+
+// lookupIcons returns the named icon set, unicode by default. Unknown names
+// fall back to unicode with an error.
+func lookupIcons(name string) (iconSet, error) {
+	if name == "" {
+		name = "unicode"
+	}
+	if s, ok := iconSets[name]; ok {
+		return s, nil
+	}
+	return iconSets["unicode"], fmt.Errorf("unknown icon set %q (want nerd, unicode or ascii)", name)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupIcons(t *testing.T) {
+	t.Run("default is unicode", func(t *testing.T) {
+		icons, err := lookupIcons("")
+		require.NoError(t, err)
+		assert.Equal(t, iconSets["unicode"], icons)
+	})
+
+	t.Run("named set", func(t *testing.T) {
+		icons, err := lookupIcons("nerd")
+		require.NoError(t, err)
+		assert.Equal(t, iconSets["nerd"], icons)
+	})
+
+	t.Run("unknown set", func(t *testing.T) {
+		icons, err := lookupIcons("emoji")
+		assert.Error(t, err)
+		assert.Equal(t, iconSets["unicode"], icons)
+	})
+}
+
+func TestRenderIcons(t *testing.T) {
+	ri := repoInfo{Project: "myproject", Branch: "main", Ahead: 2, Behind: 1, IsRepo: true}
+
+	tests := []struct {
+		name     string
+		icons    iconSet
+		expected string
+	}{
+		{"unicode", iconSets["unicode"], "myproject on ⎇ main ↑2 ↓1"},
+		{"ascii", iconSets["ascii"], "myproject on br main ^2 v1"},
+		{"nerd", iconSets["nerd"], "myproject on  main 2 1"},
+		{"empty glyph is left out", iconSet{}, "myproject on main 2 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := render(ri, input{}, renderOptions{Theme: themes["default"], Icons: tt.icons})
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestIconSetsComplete(t *testing.T) {
+	for name, set := range iconSets {
+		v := reflect.ValueOf(set)
+		for i := range v.NumField() {
+			assert.NotEmpty(t, v.Field(i).String(), "%s has no %s glyph", name, v.Type().Field(i).Name)
+		}
+	}
+}
+
+func TestRenderTagAndStash(t *testing.T) {
+	ri := repoInfo{Project: "myproject", Branch: "main", Tag: "v1.2.0", Stashes: 2, IsRepo: true}
+	assert.Equal(t, "myproject on ⎇ main ⚑v1.2.0 ≡2", render(ri, input{}, renderOptions{Icons: iconSets["unicode"]}))
+	assert.Equal(t, "myproject on br main tag:v1.2.0 $2", render(ri, input{}, renderOptions{Icons: iconSets["ascii"]}))
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// envFlags collects repeated -env KEY=VALUE flags.
+type envFlags []string
+
+func (e *envFlags) String() string { return strings.Join(*e, ",") }
+
+func (e *envFlags) Set(s string) error {
+	if k, _, ok := strings.Cut(s, "="); !ok || k == "" {
+		return fmt.Errorf("want KEY=VALUE, got %q", s)
+	}
+	*e = append(*e, s)
+	return nil
+}
+
+// runInstall implements `statusline install` and, with remove,
+// `statusline uninstall`. It edits the Claude Code settings in place so
+// the rest of the file keeps its content and formatting, and backs the
+// original up next to it.
+func runInstall(args []string, remove bool, w io.Writer) int {
+	name := "install"
+	if remove {
+		name = "uninstall"
+	}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	project := flags.Bool("project", false, "use .claude/settings.json of the current project instead of the user settings")
+	var env envFlags
+	if !remove {
+		flags.Var(&env, "env", "set an environment variable, e.g. -env STATUSLINE_THEME=gruvbox (repeatable)")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	path, err := settingsPath(*project)
+	if err == nil {
+		err = editSettings(path, w, func(b []byte) ([]byte, error) {
+			exe, err := executable()
+			if err != nil {
+				return nil, err
+			}
+			if remove {
+				return uninstallSettings(b, exe)
+			}
+			return installSettings(b, exe, env)
+		})
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "statusline:", err)
+		return 1
+	}
+	return 0
+}
+
+// settingsPath returns the user settings, in $CLAUDE_CONFIG_DIR or
+// ~/.claude, or with project those of the repository containing cwd.
+func settingsPath(project bool) (string, error) {
+	if project {
+		dir, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		if _, root := detectVCS(dir); root != "" {
+			dir = root
+		}
+		return filepath.Join(dir, ".claude", "settings.json"), nil
+	}
+	if d := os.Getenv("CLAUDE_CONFIG_DIR"); d != "" {
+		return filepath.Join(d, "settings.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".claude", "settings.json"), nil
+}
+
+// executable returns the command Claude Code should run: the path of this
+// binary, quoted when it contains spaces as the command goes through a
+// shell.
+func executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if p, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = p
+	}
+	if strings.ContainsAny(exe, " \t") {
+		exe = `"` + exe + `"`
+	}
+	return exe, nil
+}
+
+// editSettings applies edit to the settings file at path, which need not
+// exist yet. A changed file is backed up to a timestamped copy first and
+// then replaced atomically.
+func editSettings(path string, w io.Writer, edit func([]byte) ([]byte, error)) error {
+	b, err := os.ReadFile(path)
+	mode := fs.FileMode(0o644)
+	exists := err == nil
+	switch {
+	case exists:
+		if st, err := os.Stat(path); err == nil {
+			mode = st.Mode().Perm()
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		b = []byte("{}\n")
+	default:
+		return err
+	}
+	if !json.Valid(b) {
+		return fmt.Errorf("%s is not valid JSON; fix it or move it away first", path)
+	}
+	out, err := edit(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if bytes.Equal(out, b) && exists {
+		fmt.Fprintf(w, "%s is up to date\n", path)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if exists {
+		backup, err := writeBackup(path, b, mode)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "backed up %s to %s\n", path, backup)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".settings-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "updated %s\n", path)
+	return nil
+}
+
+// writeBackup saves b next to path under a timestamped name, never
+// replacing an earlier backup.
+func writeBackup(path string, b []byte, mode fs.FileMode) (string, error) {
+	stamp := path + "." + time.Now().Format("20060102-150405")
+	for n := 0; ; n++ {
+		backup := stamp + ".bak"
+		if n > 0 {
+			backup = fmt.Sprintf("%s-%d.bak", stamp, n)
+		}
+		f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(b)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return backup, err
+	}
+}
+
+// statusLineSettings is the statusLine block of the Claude Code settings.
+type statusLineSettings struct {
+	Type    string `json:"type"`
+	Command string `json:"command"`
+	Padding int    `json:"padding"`
+}
+
+// installSettings sets the statusLine block to run exe and adds env, a list
+// of KEY=VALUE, to the env block.
+func installSettings(b []byte, exe string, env []string) ([]byte, error) {
+	unit := indentUnit(b)
+	line, err := json.MarshalIndent(statusLineSettings{Type: "command", Command: exe}, unit, unit)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = setMember(b, "statusLine", line, unit, unit); err != nil {
+		return nil, err
+	}
+	if len(env) == 0 {
+		return b, nil
+	}
+
+	m, err := jsonMembers(b)
+	if err != nil {
+		return nil, err
+	}
+	obj := []byte("{}")
+	i := m.find("env")
+	if i >= 0 {
+		obj = b[m.members[i].value:m.members[i].end]
+	}
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		val, _ := json.Marshal(v)
+		if obj, err = setMember(obj, k, val, unit+unit, unit); err != nil {
+			return nil, fmt.Errorf("env: %w", err)
+		}
+	}
+	return setMember(b, "env", obj, unit, unit)
+}
+
+// uninstallSettings removes the statusLine block if it runs exe or another
+// statusline binary, and the STATUSLINE_ variables from the env block.
+func uninstallSettings(b []byte, exe string) ([]byte, error) {
+	m, err := jsonMembers(b)
+	if err != nil {
+		return nil, err
+	}
+	if i := m.find("statusLine"); i >= 0 {
+		var line statusLineSettings
+		_ = json.Unmarshal(b[m.members[i].value:m.members[i].end], &line)
+		if line.Command != exe && !strings.Contains(strings.ToLower(line.Command), "statusline") {
+			return nil, fmt.Errorf("statusLine runs %q, not statusline; left it alone", line.Command)
+		}
+		b = deleteMember(b, m, i)
+	}
+
+	if m, err = jsonMembers(b); err != nil {
+		return nil, err
+	}
+	i := m.find("env")
+	if i < 0 {
+		return b, nil
+	}
+	obj := b[m.members[i].value:m.members[i].end]
+	removed := false
+	for {
+		em, err := jsonMembers(obj)
+		if err != nil {
+			return b, nil // env is not an object; not ours to fix
+		}
+		j := slices.IndexFunc(em.members, func(mb jsonMember) bool { return strings.HasPrefix(mb.key, "STATUSLINE_") })
+		if j < 0 {
+			if removed && len(em.members) == 0 {
+				return deleteMember(b, m, i), nil
+			}
+			break
+		}
+		obj, removed = deleteMember(obj, em, j), true
+	}
+	return concat(b[:m.members[i].value], obj, b[m.members[i].end:]), nil
+}
+
+// jsonMember locates one key of an object: start is the opening quote of the
+// key, value and end delimit its value.
+type jsonMember struct {
+	key               string
+	start, value, end int
+}
+
+// jsonObject locates the braces and members of the object in a document.
+type jsonObject struct {
+	open, close int
+	members     []jsonMember
+}
+
+func (o jsonObject) find(key string) int {
+	return slices.IndexFunc(o.members, func(m jsonMember) bool { return m.key == key })
+}
+
+// jsonMembers scans the object b consists of, recording where each member
+// is so it can be edited without reformatting the rest.
+func jsonMembers(b []byte) (jsonObject, error) {
+	var o jsonObject
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return o, errors.New("not a JSON object")
+	}
+	o.open = int(dec.InputOffset()) - 1
+	for dec.More() {
+		start := skipJSON(b, int(dec.InputOffset()), " \t\r\n,")
+		t, err := dec.Token()
+		if err != nil {
+			return o, err
+		}
+		value := skipJSON(b, int(dec.InputOffset()), " \t\r\n:")
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return o, err
+		}
+		o.members = append(o.members, jsonMember{key: t.(string), start: start, value: value, end: int(dec.InputOffset())})
+	}
+	if _, err := dec.Token(); err != nil {
+		return o, err
+	}
+	o.close = int(dec.InputOffset()) - 1
+	return o, nil
+}
+
+func skipJSON(b []byte, i int, chars string) int {
+	for i < len(b) && strings.IndexByte(chars, b[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// setMember sets key of the object b to value, replacing the old value in
+// place or appending a new member on its own line indented by indent. unit
+// is one level of indentation, taken off indent for the closing brace of an
+// empty object.
+func setMember(b []byte, key string, value []byte, indent, unit string) ([]byte, error) {
+	o, err := jsonMembers(b)
+	if err != nil {
+		return nil, err
+	}
+	if i := o.find(key); i >= 0 {
+		m := o.members[i]
+		return concat(b[:m.value], value, b[m.end:]), nil
+	}
+	k, _ := json.Marshal(key)
+	entry := fmt.Sprintf("%s%s: %s", indent, k, value)
+	if n := len(o.members); n > 0 {
+		last := o.members[n-1].end
+		return concat(b[:last], []byte(",\n"+entry), b[last:]), nil
+	}
+	closing := strings.TrimSuffix(indent, unit)
+	return concat(b[:o.open+1], []byte("\n"+entry+"\n"+closing), b[o.close:]), nil
+}
+
+// deleteMember removes member i of the object o scanned from b, with the
+// comma and space that separate it from its neighbours.
+func deleteMember(b []byte, o jsonObject, i int) []byte {
+	ms := o.members
+	switch {
+	case i+1 < len(ms):
+		return concat(b[:ms[i].start], b[ms[i+1].start:])
+	case i > 0:
+		return concat(b[:ms[i-1].end], b[ms[i].end:])
+	}
+	return concat(b[:o.open+1], b[o.close:])
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// indentUnit guesses the indentation of a JSON document from its first
+// indented line, two spaces by default.
+func indentUnit(b []byte) string {
+	for line := range bytes.SplitSeq(b, []byte("\n")) {
+		if n := len(line) - len(bytes.TrimLeft(line, " \t")); n > 0 && n < len(line) {
+			return string(line[:n])
+		}
+	}
+	return "  "
+}
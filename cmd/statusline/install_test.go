@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSettings = `{
+    "model": "opus",
+    "permissions": {"allow": ["Bash(ls:*)"]},
+    "env": {
+        "FOO": "bar"
+    }
+}
+`
+
+func TestInstallSettings(t *testing.T) {
+	b, err := installSettings([]byte(testSettings), "/bin/statusline", []string{"STATUSLINE_THEME=gruvbox", "FOO=baz"})
+	require.NoError(t, err)
+	assert.Equal(t, `{
+    "model": "opus",
+    "permissions": {"allow": ["Bash(ls:*)"]},
+    "env": {
+        "FOO": "baz",
+        "STATUSLINE_THEME": "gruvbox"
+    },
+    "statusLine": {
+        "type": "command",
+        "command": "/bin/statusline",
+        "padding": 0
+    }
+}
+`, string(b))
+
+	again, err := installSettings(b, "/bin/statusline", []string{"STATUSLINE_THEME=gruvbox"})
+	require.NoError(t, err)
+	assert.Equal(t, string(b), string(again), "installing twice changes nothing")
+}
+
+func TestInstallSettingsEmpty(t *testing.T) {
+	b, err := installSettings([]byte("{}\n"), `C:\bin\statusline.exe`, []string{"STATUSLINE_FETCH=1"})
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "statusLine": {
+    "type": "command",
+    "command": "C:\\bin\\statusline.exe",
+    "padding": 0
+  },
+  "env": {
+    "STATUSLINE_FETCH": "1"
+  }
+}
+`, string(b))
+}
+
+func TestUninstallSettings(t *testing.T) {
+	installed, err := installSettings([]byte(testSettings), "/bin/statusline", []string{"STATUSLINE_THEME=gruvbox"})
+	require.NoError(t, err)
+	b, err := uninstallSettings(installed, "/bin/statusline")
+	require.NoError(t, err)
+	assert.Equal(t, testSettings, string(b))
+
+	installed, _ = installSettings([]byte("{}"), "/opt/sl", []string{"STATUSLINE_FETCH=1"})
+	b, err = uninstallSettings(installed, "/opt/sl")
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(b))
+
+	_, err = uninstallSettings([]byte(`{"statusLine": {"type": "command", "command": "ccline"}}`), "/opt/sl")
+	assert.ErrorContains(t, err, `statusLine runs "ccline"`)
+
+	b, err = uninstallSettings([]byte(`{"env": {}}`), "/opt/sl")
+	require.NoError(t, err)
+	assert.Equal(t, `{"env": {}}`, string(b), "env the user left empty stays")
+}
+
+func TestDeleteMember(t *testing.T) {
+	for _, tt := range []struct{ in, key, want string }{
+		{`{"a": 1, "b": 2, "c": 3}`, "a", `{"b": 2, "c": 3}`},
+		{`{"a": 1, "b": 2, "c": 3}`, "b", `{"a": 1, "c": 3}`},
+		{`{"a": 1, "b": 2, "c": 3}`, "c", `{"a": 1, "b": 2}`},
+		{"{\n  \"a\": [1, {\"x\": 2}]\n}", "a", "{}"},
+	} {
+		o, err := jsonMembers([]byte(tt.in))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, string(deleteMember([]byte(tt.in), o, o.find(tt.key))), tt.in)
+	}
+}
+
+func TestIndentUnit(t *testing.T) {
+	assert.Equal(t, "  ", indentUnit([]byte("{}")))
+	assert.Equal(t, "\t", indentUnit([]byte("{\n\t\"a\": 1\n}")))
+	assert.Equal(t, "    ", indentUnit([]byte(testSettings)))
+}
+
+func TestEditSettings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".claude", "settings.json")
+	var out bytes.Buffer
+	add := func(b []byte) ([]byte, error) { return installSettings(b, "/bin/statusline", nil) }
+
+	require.NoError(t, editSettings(path, &out, add))
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"command": "/bin/statusline"`)
+	backups, _ := filepath.Glob(path + ".*.bak")
+	assert.Empty(t, backups, "nothing to back up")
+
+	require.NoError(t, editSettings(path, &out, add))
+	assert.Contains(t, out.String(), "is up to date")
+
+	remove := func(b []byte) ([]byte, error) { return uninstallSettings(b, "/bin/statusline") }
+	require.NoError(t, editSettings(path, &out, remove))
+	require.NoError(t, editSettings(path, &out, add))
+	backups, _ = filepath.Glob(path + ".*.bak")
+	require.Len(t, backups, 2, "backups made within a second are kept apart")
+
+	require.NoError(t, os.WriteFile(path, []byte("{oops"), 0o600))
+	assert.ErrorContains(t, editSettings(path, &out, add), "not valid JSON")
+}
+
+func TestSettingsPath(t *testing.T) {
+	t.Setenv("CLAUDE_CONFIG_DIR", "/etc/claude")
+	path, err := settingsPath(false)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/etc/claude", "settings.json"), path)
+
+	root := testRepo(t)
+	sub := filepath.Join(root, "sub")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+	t.Chdir(sub)
+	path, err = settingsPath(true)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, ".claude", "settings.json"), path)
+}
+
+func TestEnvFlags(t *testing.T) {
+	var e envFlags
+	require.NoError(t, e.Set("STATUSLINE_THEME=gruvbox"))
+	require.NoError(t, e.Set("EMPTY="))
+	assert.Error(t, e.Set("NOVALUE"))
+	assert.Error(t, e.Set("=x"))
+	assert.Equal(t, envFlags{"STATUSLINE_THEME=gruvbox", "EMPTY="}, e)
+}
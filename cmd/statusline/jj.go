@@ -0,0 +1,42 @@
+package main
+
+import "strings"
+
+// jjTemplate prints the working-copy commit as tab-separated fields: change
+// id, local bookmarks, conflict and empty flags.
+const jjTemplate = `change_id.shortest() ++ "\t" ++ local_bookmarks.map(|b| b.name()).join(",") ++ "\t" ++ if(conflict, "1", "0") ++ "\t" ++ if(empty, "1", "0")`
+
+// jjBackend reads repository state from the jj CLI. Commands run with
+// --ignore-working-copy so that rendering never snapshots the working copy
+// or takes the working-copy lock; the state is that of the last jj command.
+type jjBackend struct{}
+
+func (jjBackend) Name() string { return "jj" }
+
+func (jjBackend) Root(dir string) (string, bool) {
+	return findUp(dir, ".jj")
+}
+
+// Collect falls back to git when jj fails, e.g. when it is not installed in
+// a colocated repository.
+func (jjBackend) Collect(root string) repoInfo {
+	var ri repoInfo
+	out := run(root, "jj", "log", "--no-graph", "--ignore-working-copy", "--color=never", "-r", "@", "-T", jjTemplate)
+	ri.ChangeID, ri.Branch, ri.Conflict, ri.Empty = parseJJLog(out)
+	if ri.ChangeID == "" {
+		if _, ok := (gitBackend{}).Root(root); ok {
+			return gitBackend{}.Collect(root)
+		}
+		return ri
+	}
+	ri.HasTracked = !ri.Empty
+	return ri
+}
+
+func parseJJLog(s string) (changeID, bookmarks string, conflict, empty bool) {
+	f := strings.Split(strings.TrimRight(s, "\r\n"), "\t")
+	if len(f) != 4 {
+		return "", "", false, false
+	}
+	return f[0], f[1], f[2] == "1", f[3] == "1"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJJLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		changeID  string
+		bookmarks string
+		conflict  bool
+		empty     bool
+	}{
+		{"empty working copy", "kx\t\t0\t1\n", "kx", "", false, true},
+		{"bookmarks and changes", "qpvu\tmain,feature\t0\t0", "qpvu", "main,feature", false, false},
+		{"conflict", "zz\tmain\t1\t0", "zz", "main", true, false},
+		{"no output", "", "", "", false, false},
+		{"malformed", "kx\tmain", "", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changeID, bookmarks, conflict, empty := parseJJLog(tt.input)
+			assert.Equal(t, tt.changeID, changeID)
+			assert.Equal(t, tt.bookmarks, bookmarks)
+			assert.Equal(t, tt.conflict, conflict)
+			assert.Equal(t, tt.empty, empty)
+		})
+	}
+}
+
+func TestJJRoot(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".jj"), 0o755))
+	sub := filepath.Join(dir, "a", "b")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+
+	root, ok := jjBackend{}.Root(sub)
+	assert.True(t, ok)
+	assert.Equal(t, dir, root)
+
+	_, ok = jjBackend{}.Root(t.TempDir())
+	assert.False(t, ok)
+}
+
+func TestDetectVCSColocated(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, exec.Command("git", "init", "-q", dir).Run())
+
+	b, root := detectVCS(dir)
+	assert.Equal(t, "git", b.Name())
+	assert.Equal(t, dir, root)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".jj"), 0o755))
+	b, root = detectVCS(dir)
+	assert.Equal(t, "jj", b.Name())
+	assert.Equal(t, dir, root)
+
+	if _, err := exec.LookPath("jj"); err != nil {
+		ri := collect(dir)
+		assert.True(t, ri.IsRepo)
+		assert.Equal(t, "jj", ri.VCS)
+		assert.NotEmpty(t, ri.Branch, "falls back to git without jj")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultDanger are the patterns of production contexts and profiles.
+var defaultDanger = []string{"*prod*"}
+
+// kubeInfo is the current Kubernetes context and its namespace.
+type kubeInfo struct {
+	Context   string `json:"context"`
+	Namespace string `json:"namespace"`
+}
+
+// kubeContext returns the current context of the kubeconfig and its
+// namespace, reading the files directly instead of running kubectl. As with
+// kubectl, the files in $KUBECONFIG are merged with the first one to set a
+// value winning.
+func kubeContext() (name, namespace string, ok bool) {
+	namespaces := map[string]string{}
+	for _, f := range kubeconfigFiles() {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var kc struct {
+			CurrentContext string `yaml:"current-context"`
+			Contexts       []struct {
+				Name    string `yaml:"name"`
+				Context struct {
+					Namespace string `yaml:"namespace"`
+				} `yaml:"context"`
+			} `yaml:"contexts"`
+		}
+		if yaml.Unmarshal(b, &kc) != nil {
+			continue
+		}
+		if name == "" {
+			name = kc.CurrentContext
+		}
+		for _, c := range kc.Contexts {
+			if _, seen := namespaces[c.Name]; !seen {
+				namespaces[c.Name] = c.Context.Namespace
+			}
+		}
+	}
+	if name == "" {
+		return "", "", false
+	}
+	namespace = namespaces[name]
+	if namespace == "" {
+		namespace = "default"
+	}
+	return name, namespace, true
+}
+
+// kubeconfigFiles returns the files listed in $KUBECONFIG, or ~/.kube/config.
+func kubeconfigFiles() []string {
+	if s := os.Getenv("KUBECONFIG"); s != "" {
+		return filepath.SplitList(s)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// matchesAny reports whether s matches one of the glob patterns, ignoring
+// case. Unlike path.Match, "*" also matches "/", which is common in cluster
+// and resource names such as "arn:aws:eks:...:cluster/prod".
+func matchesAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		expr := regexp.QuoteMeta(strings.ToLower(p))
+		expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+		if ok, _ := regexp.MatchString("^"+expr+"$", strings.ToLower(s)); ok {
+			return true
+		}
+	}
+	return false
+}
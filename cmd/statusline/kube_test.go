@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: prod-eu
+contexts:
+- name: prod-eu
+  context:
+    cluster: prod-eu
+    namespace: payments
+- name: kind-dev
+  context:
+    cluster: kind-dev
+`
+
+func TestKubeContext(t *testing.T) {
+	dir := t.TempDir()
+	prod := filepath.Join(dir, "prod")
+	require.NoError(t, os.WriteFile(prod, []byte(testKubeconfig), 0o600))
+	dev := filepath.Join(dir, "dev.json")
+	require.NoError(t, os.WriteFile(dev, []byte(`{"current-context": "kind-dev", "contexts": [{"name": "kind-dev", "context": {"namespace": "ignored"}}]}`), 0o600))
+
+	tests := []struct {
+		name       string
+		kubeconfig string
+		ctx, ns    string
+		ok         bool
+	}{
+		{"single file", prod, "prod-eu", "payments", true},
+		{"json", dev, "kind-dev", "ignored", true},
+		{"first file wins", prod + string(os.PathListSeparator) + dev, "prod-eu", "payments", true},
+		{"order decides", dev + string(os.PathListSeparator) + prod, "kind-dev", "ignored", true},
+		{"missing file", filepath.Join(dir, "missing"), "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KUBECONFIG", tt.kubeconfig)
+			ctx, ns, ok := kubeContext()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.ctx, ctx)
+			assert.Equal(t, tt.ns, ns)
+		})
+	}
+}
+
+func TestKubeContextDefaultNamespace(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(p, []byte("current-context: kind-dev\n"), 0o600))
+	t.Setenv("KUBECONFIG", p)
+	ctx, ns, ok := kubeContext()
+	assert.True(t, ok)
+	assert.Equal(t, "kind-dev", ctx)
+	assert.Equal(t, "default", ns)
+}
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected bool
+	}{
+		{"prod-eu", true},
+		{"arn:aws:eks:eu-west-1:123:cluster/Production", true},
+		{"live", true},
+		{"live-eu", false},
+		{"staging", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchesAny([]string{"*prod*", "live"}, tt.s))
+		})
+	}
+}
+
+func TestKubeSegment(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th, Icons: iconSets["unicode"]}
+	ri := repoInfo{Kube: &kubeInfo{Context: "prod-eu", Namespace: "payments"}}
+
+	seg, ok := kubeSegment(ri, input{}, opts)
+	require.True(t, ok)
+	assert.Equal(t, []span{{"⎈", th.Kube, ""}, {"prod-eu:payments", th.Kube, ""}}, seg.Spans)
+
+	opts.Danger = defaultDanger
+	seg, _ = kubeSegment(ri, input{}, opts)
+	assert.Equal(t, th.Danger, seg.Spans[1].Style)
+	assert.Equal(t, th.Danger, seg.Block)
+
+	_, ok = kubeSegment(repoInfo{}, input{}, opts)
+	assert.False(t, ok)
+}
@@ -5,9 +5,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -22,55 +22,226 @@ var (
 )
 
 const (
-	colGreen     = "38;5;82"
-	colYellow    = "38;5;220"
-	colRed       = "38;5;196"
 	esc          = "\x1b"
 	maxBranchLen = 48
-)
 
-type input struct {
-	Cwd string `json:"cwd"`
-}
+	// branchUnknown stands in for the branch when git timed out.
+	branchUnknown = "…"
+)
 
 type repoInfo struct {
-	Project                         string
-	Branch                          string
-	Ahead, Behind                   int
-	HasTracked, HasUntracked, IsGit bool
+	Project      string `json:"project"`
+	Root         string `json:"root"`
+	SubProject   string `json:"sub_project"` // path of the package containing cwd, relative to Root
+	VCS          string `json:"vcs"`         // backend name, e.g. "git" or "jj"
+	Branch       string `json:"branch"`      // branch, or jj bookmarks joined by ","
+	Commit       string `json:"commit"`      // short hash, set when detached
+	Tag          string `json:"tag"`         // git tag pointing at HEAD
+	Stashes      int    `json:"stashes"`     // number of git stash entries
+	Operation    string `json:"operation"`   // git operation in progress, e.g. "rebase 2/5"
+	ChangeID     string `json:"change_id"`   // jj change id, shortest unique prefix
+	Phase        string `json:"phase"`       // hg/sl phase of the working-copy parent
+	Remote       string `json:"remote"`      // URL of the default remote, set when links are on
+	Ahead        int    `json:"ahead"`
+	Behind       int    `json:"behind"`
+	HasTracked   bool   `json:"has_tracked"`
+	HasUntracked bool   `json:"has_untracked"`
+	IsRepo       bool   `json:"is_repo"`
+	Conflict     bool   `json:"conflict"` // jj working-copy commit state
+	Empty        bool   `json:"empty"`
+
+	UntrackedUnknown bool `json:"untracked_unknown"` // git status skipped untracked files
+	Stale            bool `json:"stale"`             // git timed out; state of an earlier render
+
+	Toolchains map[string]string `json:"toolchains,omitempty"` // versions by segment name, e.g. "go"
+
+	// The session's surroundings, set by gather when the layout shows them.
+	Envs  []string  `json:"envs,omitempty"` // see environments
+	Kube  *kubeInfo `json:"kube,omitempty"`
+	AWS   *awsInfo  `json:"aws,omitempty"`
+	GCP   *gcpInfo  `json:"gcp,omitempty"`
+	Azure string    `json:"azure,omitempty"` // default subscription
 }
 
 func main() {
-	var showVersion bool
+	var (
+		showVersion bool
+		colorFlag   string
+		debug       bool
+	)
 	flag.BoolVar(&showVersion, "v", false, "show version and exit")
 	flag.BoolVar(&showVersion, "version", false, "show version and exit")
+	flag.StringVar(&colorFlag, "color", "auto", "colorize output: auto, always or never")
+	flag.BoolVar(&debug, "debug", false, "log commands, payload and output to stderr (or to the file in STATUSLINE_DEBUG)")
 	flag.Parse()
 
+	if target := debugTarget(os.Getenv("STATUSLINE_DEBUG"), debug); target != "" {
+		stop, err := startDebug(target)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "statusline:", err)
+		}
+		defer stop()
+	}
+
 	if showVersion {
 		fmt.Printf("statusline %s (built: %s)\n", version, build)
 		os.Exit(0)
 	}
+	mode, err := parseColorMode(colorFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "statusline:", err)
+		os.Exit(2)
+	}
+	switch flag.Arg(0) {
+	case "daemon":
+		os.Exit(runDaemon(flag.Args()[1:]))
+	case "doctor":
+		os.Exit(runDoctor(flag.Args()[1:], os.Stdout))
+	case "install", "uninstall":
+		os.Exit(runInstall(flag.Args()[1:], flag.Arg(0) == "uninstall", os.Stdout))
+	case "preview":
+		os.Exit(runPreview(flag.Args()[1:], resolveColor(mode), os.Stdout))
+	}
 
-	cwd := readCwd(os.Stdin)
+	dir := configDir()
+	cfg, _ := loadConfig(dir)
+	cfg.applyVCS()
+	if cfg.Budget > 0 {
+		runDeadline = time.Now().Add(time.Duration(cfg.Budget))
+	}
+	opts := newRenderOptions(cfg, dir, resolveColor(mode))
+
+	in := readInput(os.Stdin)
+	if debugLog != nil {
+		b, _ := json.Marshal(in)
+		debugf("payload: %s", b)
+	}
+	cwd := in.currentDir()
 	if cwd == "" {
 		if d, err := os.Getwd(); err == nil {
 			cwd = d
 		}
 	}
-	fmt.Println(render(collect(cwd)))
+	ri := gather(cwd, cfg, opts)
+	opts.Plugins = runLayoutPlugins(cfg, opts.Lines, cwd, ri, in)
+	out := render(ri, in, opts)
+	debugf("render: %q", out)
+	fmt.Println(out)
 }
 
-func collect(cwd string) repoInfo {
-	var ri repoInfo
-	ri.Project = filepath.Base(cwd)
+// newRenderOptions resolves the settings of cfg, read from the config
+// directory dir, that shape the line.
+func newRenderOptions(cfg config, dir string, color bool) renderOptions {
+	th, _ := loadTheme(dir, cfg.Theme)
+	icons, _ := lookupIcons(cfg.Icons)
+	return renderOptions{
+		Color:  color,
+		Theme:  th,
+		Icons:  icons,
+		Mode:   cfg.Mode,
+		Links:  cfg.Links,
+		Forges: cfg.Forges,
+		Lines:  cfg.Lines,
+		Width:  cfg.Width,
+
+		SubProject: cfg.SubProject,
+		Danger:     cfg.Danger,
+	}
+}
 
-	root := git(cwd, "rev-parse", "--show-toplevel")
-	if root == "" {
-		return ri
+// gather collects what the layout shows about cwd: the repository state,
+// from the daemon when it runs, its remote and sub-project, toolchain
+// versions and surroundings.
+func gather(cwd string, cfg config, opts renderOptions) repoInfo {
+	ri, ok := daemonCollect(cwd)
+	if !ok {
+		ri = collect(cwd)
+	}
+	if ri.IsRepo && opts.Links && opts.Color {
+		ri.Remote = remoteURL(cwd)
+	}
+	if ri.IsRepo && cfg.SubProject != subProjectOff {
+		ri.SubProject = findSubProject(cwd, ri.Root, cfg.Markers)
 	}
-	ri.IsGit = true
+	if layoutUses(opts.Lines, toolchainNames...) {
+		root := ri.Root
+		if root == "" {
+			root = cwd
+		}
+		ri.Toolchains = detectToolchains(cwd, root)
+	}
+	gatherSurroundings(&ri, opts.Lines)
+	return ri
+}
+
+// gatherSurroundings reads the environments, Kubernetes context and cloud
+// accounts shown by layout. They come from the environment of this process,
+// not the daemon's.
+func gatherSurroundings(ri *repoInfo, layout []lineLayout) {
+	if layoutUses(layout, "env") {
+		ri.Envs = environments()
+	}
+	if layoutUses(layout, "kube") {
+		if name, ns, ok := kubeContext(); ok {
+			ri.Kube = &kubeInfo{Context: name, Namespace: ns}
+		}
+	}
+	if layoutUses(layout, "aws") {
+		if profile, region, ok := awsProfile(); ok {
+			ri.AWS = &awsInfo{Profile: profile, Region: region}
+		}
+	}
+	if layoutUses(layout, "gcp") {
+		if name, project, ok := gcloudConfig(); ok {
+			ri.GCP = &gcpInfo{Config: name, Project: project}
+		}
+	}
+	if layoutUses(layout, "azure") {
+		ri.Azure, _ = azureSubscription()
+	}
+}
+
+// runLayoutPlugins runs the plugins in layout in cwd, handing them ri and
+// in, which may be collected or made up as by statusline preview.
+func runLayoutPlugins(cfg config, layout []lineLayout, cwd string, ri repoInfo, in input) map[string]pluginOutput {
+	names := layoutPlugins(layout, cfg.Plugins)
+	if len(names) == 0 {
+		return nil
+	}
+	return runPlugins(cfg.Plugins, names, cwd, ri, in)
+}
+
+// collect gathers the state of the repository containing cwd. Outside a
+// repository only the directory name is set.
+func collect(cwd string) repoInfo {
+	b, root := detectVCS(cwd)
+	if b == nil {
+		return repoInfo{Project: filepath.Base(cwd)}
+	}
+	ri := b.Collect(root)
+	ri.Root = root
 	ri.Project = filepath.Base(root)
+	ri.VCS = b.Name()
+	ri.IsRepo = true
+	return ri
+}
+
+// gitBackend reads repository state from the git CLI.
+type gitBackend struct{}
+
+func (gitBackend) Name() string { return "git" }
 
+func (gitBackend) Root(dir string) (string, bool) {
+	root, err := gitErr(dir, "rev-parse", "--show-toplevel")
+	if errors.Is(err, errTimeout) {
+		// a slow git is no reason to pretend there is no repository
+		return findUp(dir, ".git")
+	}
+	return root, err == nil && root != ""
+}
+
+func (gitBackend) Collect(root string) repoInfo {
+	var ri repoInfo
 	if os.Getenv("STATUSLINE_FETCH") == "1" && shouldFetch(root) {
 		up := git(root, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
 		if up != "" {
@@ -80,8 +251,33 @@ func collect(cwd string) repoInfo {
 		}
 	}
 
-	status := git(root, "status", "--porcelain=2", "--branch", "--ignore-submodules=dirty")
+	// the cached state is reused while nothing git status looks at changed
+	key := gitStateKey(root)
+	if key != "" {
+		key += fmt.Sprintf("|%s|%t", gitOptions.Untracked, gitOptions.FSMonitor)
+	}
+	cache := cachePath("repo", root)
+	if os.Getenv("STATUSLINE_CACHE") == "0" {
+		cache = ""
+	}
+	var c repoCache
+	if key != "" && readCache(cache, &c) && c.Key == key {
+		debugf("repo cache hit for %s", root)
+		return c.Info
+	}
+
+	status, unknown, err := gitStatus(root)
+	if errors.Is(err, errTimeout) {
+		// the last known state is better than none, and "…" better than
+		// "no-branch"
+		if readCache(cache, &c) {
+			c.Info.Stale = true
+			return c.Info
+		}
+		return repoInfo{Branch: branchUnknown, Stale: true}
+	}
 	ri.Branch, ri.Ahead, ri.Behind, ri.HasTracked, ri.HasUntracked = parseStatus(status)
+	ri.UntrackedUnknown = unknown
 
 	if ri.Branch == "" {
 		ri.Branch = "no-branch"
@@ -89,68 +285,155 @@ func collect(cwd string) repoInfo {
 	if ri.Branch == "(detached)" {
 		if sha := git(root, "rev-parse", "--short", "HEAD"); sha != "" {
 			ri.Branch = "detached@" + sha
+			ri.Commit = sha
 		}
 	}
+	ri.Tag, _, _ = strings.Cut(git(root, "tag", "--points-at", "HEAD"), "\n")
+	ri.Stashes = stashCount(root)
+	if gitDir, _, ok := gitDirs(root); ok {
+		ri.Operation = gitOperation(gitDir)
+	}
+	if err == nil {
+		// without a key, the state is only kept for when git times out
+		writeCache(cache, repoCache{Key: key, Info: ri})
+	}
 	return ri
 }
 
-func render(ri repoInfo) string {
-	if !ri.IsGit {
-		return ri.Project
-	}
-	iconCol := colGreen
-	switch {
-	case ri.HasUntracked:
-		iconCol = colRed
-	case ri.HasTracked:
-		iconCol = colYellow
+// stashCount returns the number of stash entries, the lines of the stash
+// reflog.
+func stashCount(root string) int {
+	_, commonDir, ok := gitDirs(root)
+	if !ok {
+		return 0
 	}
-	icon := colorizeBold("⎇", iconCol)
+	n := 0
+	forEachLine(filepath.Join(commonDir, "logs", "refs", "stash"), func(string) { n++ })
+	return n
+}
 
-	arrows := ""
-	if ri.Ahead > 0 {
-		arrows += " " + colorize(fmt.Sprintf("↑%d", ri.Ahead), colGreen)
+// gitOperation returns the operation in progress in gitDir, as the git
+// prompt shows it: "rebase" or "am" with the step, e.g. "rebase 2/5", or
+// "merge", "cherry-pick", "revert" or "bisect". It is "" when there is none.
+func gitOperation(gitDir string) string {
+	step := func(dir, num, total string) string {
+		n, _ := os.ReadFile(filepath.Join(dir, num))
+		t, _ := os.ReadFile(filepath.Join(dir, total))
+		if len(n) == 0 || len(t) == 0 {
+			return ""
+		}
+		return " " + strings.TrimSpace(string(n)) + "/" + strings.TrimSpace(string(t))
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(gitDir, name))
+		return err == nil
 	}
-	if ri.Behind > 0 {
-		arrows += " " + colorize(fmt.Sprintf("↓%d", ri.Behind), colRed)
+	switch {
+	case exists("rebase-merge"):
+		return "rebase" + step(filepath.Join(gitDir, "rebase-merge"), "msgnum", "end")
+	case exists("rebase-apply"):
+		op := "rebase"
+		if exists(filepath.Join("rebase-apply", "applying")) {
+			op = "am"
+		}
+		return op + step(filepath.Join(gitDir, "rebase-apply"), "next", "last")
+	case exists("MERGE_HEAD"):
+		return "merge"
+	case exists("CHERRY_PICK_HEAD"):
+		return "cherry-pick"
+	case exists("REVERT_HEAD"):
+		return "revert"
+	case exists("BISECT_LOG"):
+		return "bisect"
 	}
+	return ""
+}
+
+// Errors of runErr, to be tested with errors.Is. A missing binary is
+// reported as exec.ErrNotFound.
+var (
+	errTimeout = errors.New("timed out")
+	errNotRepo = errors.New("not a repository")
+)
+
+// Timeouts of VCS commands, set from the config in main. runDeadline ends
+// all commands of a render; it is zero when there is no total budget.
+var (
+	runTimeout  = 300 * time.Millisecond
+	runDeadline time.Time
+)
 
-	return fmt.Sprintf("%s on %s %s%s", ri.Project, icon, shorten(ri.Branch, maxBranchLen), arrows)
+func git(dir string, args ...string) string {
+	out, _ := gitErr(dir, args...)
+	return out
 }
 
-func readCwd(r io.Reader) string {
-	var in input
-	b, _ := io.ReadAll(r)
-	if len(b) == 0 {
-		return ""
-	}
-	_ = json.Unmarshal(b, &in)
-	return strings.TrimSpace(in.Cwd)
+func gitErr(dir string, args ...string) (string, error) {
+	return runErr(dir, nil, "git", args...)
 }
 
-func git(dir string, args ...string) string {
-	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+// run executes a VCS command in dir and returns its trimmed stdout, or ""
+// when it fails or does not finish in time.
+func run(dir, name string, args ...string) string {
+	return runEnv(dir, nil, name, args...)
+}
+
+// runEnv is run with env added to the environment of the command.
+func runEnv(dir string, env []string, name string, args ...string) string {
+	out, _ := runErr(dir, env, name, args...)
+	return out
+}
+
+// runErr is runEnv reporting why a command failed: errTimeout when it ran
+// out of time, exec.ErrNotFound when the binary is missing, errNotRepo when
+// dir is not in a repository, or the exit status and stderr otherwise.
+func runErr(dir string, env []string, name string, args ...string) (string, error) {
+	timeout := runTimeout
+	if !runDeadline.IsZero() {
+		timeout = min(timeout, time.Until(runDeadline))
+	}
+	if timeout <= 0 {
+		return "", fmt.Errorf("%s: %w: no time left", name, errTimeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Dir = dir
-	var out bytes.Buffer
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var out, stderr bytes.Buffer
 	cmd.Stdout = &out
-	_ = cmd.Run()
-	return strings.TrimSpace(out.String())
-}
-
-func colorize(s, col string) string {
-	if os.Getenv("STATUSLINE_NO_COLOR") == "1" {
-		return s
+	cmd.Stderr = &stderr
+	start := time.Now()
+	err := cmd.Run()
+	s := strings.TrimSpace(out.String())
+	msg := strings.TrimSpace(stderr.String())
+	if debugLog != nil {
+		debugCommand(cmd, time.Since(start), ctx.Err() == context.DeadlineExceeded, msg)
+	}
+	switch {
+	case err == nil:
+		return s, nil
+	case ctx.Err() == context.DeadlineExceeded:
+		return s, fmt.Errorf("%s: %w after %v", name, errTimeout, timeout)
+	case isNotRepo(msg):
+		return s, fmt.Errorf("%s: %w", name, errNotRepo)
+	case msg != "":
+		return s, fmt.Errorf("%s: %w: %s", name, err, msg)
 	}
-	return esc + "[" + col + "m" + s + esc + "[0m"
+	return s, fmt.Errorf("%s: %w", name, err)
 }
 
-func colorizeBold(s, col string) string {
-	if os.Getenv("STATUSLINE_NO_COLOR") == "1" {
-		return s
+// isNotRepo recognizes the complaints of git, hg/sl and jj about a directory
+// outside a repository.
+func isNotRepo(stderr string) bool {
+	for _, m := range []string{"not a git repository", "no repository found", "There is no jj repo"} {
+		if strings.Contains(stderr, m) {
+			return true
+		}
 	}
-	return esc + "[1;" + col + "m" + s + esc + "[0m"
+	return false
 }
 
 func parseStatus(s string) (branch string, ahead, behind int, hasTracked, hasUntracked bool) {
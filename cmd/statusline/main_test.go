@@ -1,14 +1,34 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// TestMain keeps the tests away from the user's cache directory and daemon.
+// Tests that look at the cache set STATUSLINE_CACHE_DIR themselves.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "statusline-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("STATUSLINE_CACHE_DIR", dir)
+	os.Setenv("STATUSLINE_DAEMON", "0")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestParseStatus(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -182,7 +202,7 @@ func TestRender(t *testing.T) {
 			name: "non-git repository",
 			repoInfo: repoInfo{
 				Project: "myproject",
-				IsGit:   false,
+				IsRepo:  false,
 			},
 			expected: "myproject",
 		},
@@ -191,7 +211,7 @@ func TestRender(t *testing.T) {
 			repoInfo: repoInfo{
 				Project: "myproject",
 				Branch:  "main",
-				IsGit:   true,
+				IsRepo:  true,
 			},
 			expected: "myproject on \x1b[1;38;5;82m⎇\x1b[0m main",
 		},
@@ -200,7 +220,7 @@ func TestRender(t *testing.T) {
 			repoInfo: repoInfo{
 				Project:    "myproject",
 				Branch:     "feature",
-				IsGit:      true,
+				IsRepo:     true,
 				HasTracked: true,
 			},
 			expected: "myproject on \x1b[1;38;5;220m⎇\x1b[0m feature",
@@ -210,7 +230,7 @@ func TestRender(t *testing.T) {
 			repoInfo: repoInfo{
 				Project:      "myproject",
 				Branch:       "develop",
-				IsGit:        true,
+				IsRepo:       true,
 				HasUntracked: true,
 			},
 			expected: "myproject on \x1b[1;38;5;196m⎇\x1b[0m develop",
@@ -222,7 +242,7 @@ func TestRender(t *testing.T) {
 				Branch:  "feature",
 				Ahead:   2,
 				Behind:  1,
-				IsGit:   true,
+				IsRepo:  true,
 			},
 			expected: "myproject on \x1b[1;38;5;82m⎇\x1b[0m feature \x1b[38;5;82m↑2\x1b[0m \x1b[38;5;196m↓1\x1b[0m",
 		},
@@ -231,7 +251,7 @@ func TestRender(t *testing.T) {
 			repoInfo: repoInfo{
 				Project: "myproject",
 				Branch:  "very-long-feature-branch-name-that-exceeds-max-length",
-				IsGit:   true,
+				IsRepo:  true,
 			},
 			expected: "myproject on \x1b[1;38;5;82m⎇\x1b[0m very-long-feature-branch-name-that-exceeds-ma...",
 		},
@@ -239,25 +259,23 @@ func TestRender(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := render(tt.repoInfo)
+			result := render(tt.repoInfo, input{}, renderOptions{Color: true, Theme: themes["default"], Icons: iconSets["unicode"]})
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
 
 func TestRenderNoColor(t *testing.T) {
-	t.Setenv("STATUSLINE_NO_COLOR", "1")
-
 	ri := repoInfo{
 		Project:      "myproject",
 		Branch:       "main",
 		Ahead:        1,
 		Behind:       2,
-		IsGit:        true,
+		IsRepo:       true,
 		HasUntracked: true,
 	}
 
-	result := render(ri)
+	result := render(ri, input{}, renderOptions{Color: false, Theme: themes["default"], Icons: iconSets["unicode"]})
 	expected := "myproject on ⎇ main ↑1 ↓2"
 	assert.Equal(t, expected, result)
 }
@@ -303,93 +321,61 @@ func TestReadCwd(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			reader := strings.NewReader(tt.input)
-			result := readCwd(reader)
+			result := readInput(reader).Cwd
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
 
-func TestColorize(t *testing.T) {
+func TestPaint(t *testing.T) {
 	tests := []struct {
 		name     string
 		text     string
-		color    string
+		style    style
 		expected string
 	}{
 		{
-			name:     "green color",
-			text:     "⎇",
-			color:    colGreen,
-			expected: "\x1b[38;5;82m⎇\x1b[0m",
-		},
-		{
-			name:     "yellow color",
+			name:     "foreground color",
 			text:     "text",
-			color:    colYellow,
+			style:    style{FG: "220"},
 			expected: "\x1b[38;5;220mtext\x1b[0m",
 		},
 		{
-			name:     "red color",
-			text:     "error",
-			color:    colRed,
-			expected: "\x1b[38;5;196merror\x1b[0m",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := colorize(tt.text, tt.color)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
-func TestColorizeNoColor(t *testing.T) {
-	t.Setenv("STATUSLINE_NO_COLOR", "1")
-
-	result := colorize("test", colGreen)
-	assert.Equal(t, "test", result)
-}
-
-func TestColorizeBold(t *testing.T) {
-	tests := []struct {
-		name     string
-		text     string
-		color    string
-		expected string
-	}{
-		{
-			name:     "bold green color",
+			name:     "bold foreground color",
 			text:     "⎇",
-			color:    colGreen,
+			style:    style{FG: "82", Bold: true},
 			expected: "\x1b[1;38;5;82m⎇\x1b[0m",
 		},
 		{
-			name:     "bold yellow color",
+			name:     "hex color",
+			text:     "error",
+			style:    style{FG: "#f38ba8"},
+			expected: "\x1b[38;2;243;139;168merror\x1b[0m",
+		},
+		{
+			name:     "background is not painted",
 			text:     "text",
-			color:    colYellow,
-			expected: "\x1b[1;38;5;220mtext\x1b[0m",
+			style:    style{FG: "82", BG: "236"},
+			expected: "\x1b[38;5;82mtext\x1b[0m",
 		},
 		{
-			name:     "bold red color",
-			text:     "error",
-			color:    colRed,
-			expected: "\x1b[1;38;5;196merror\x1b[0m",
+			name:     "empty style",
+			text:     "text",
+			style:    style{},
+			expected: "text",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := colorizeBold(tt.text, tt.color)
+			result := renderOptions{Color: true}.paint(tt.text, tt.style)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
 
-func TestColorizeBoldNoColor(t *testing.T) {
-	t.Setenv("STATUSLINE_NO_COLOR", "1")
-
-	result := colorizeBold("test", colGreen)
+func TestPaintNoColor(t *testing.T) {
+	result := renderOptions{Color: false}.paint("test", style{FG: "82", Bold: true})
 	assert.Equal(t, "test", result)
 }
 
@@ -401,7 +387,7 @@ func TestRepoInfo(t *testing.T) {
 		Behind:       2,
 		HasTracked:   true,
 		HasUntracked: false,
-		IsGit:        true,
+		IsRepo:       true,
 	}
 
 	assert.Equal(t, "testproject", ri.Project)
@@ -410,7 +396,7 @@ func TestRepoInfo(t *testing.T) {
 	assert.Equal(t, 2, ri.Behind)
 	assert.True(t, ri.HasTracked)
 	assert.False(t, ri.HasUntracked)
-	assert.True(t, ri.IsGit)
+	assert.True(t, ri.IsRepo)
 }
 
 func TestGit(t *testing.T) {
@@ -435,7 +421,7 @@ func TestCollect(t *testing.T) {
 	t.Run("non-git directory", func(t *testing.T) {
 		ri := collect("/tmp")
 		assert.Equal(t, "tmp", ri.Project)
-		assert.False(t, ri.IsGit)
+		assert.False(t, ri.IsRepo)
 		assert.Equal(t, "", ri.Branch)
 		assert.Equal(t, 0, ri.Ahead)
 		assert.Equal(t, 0, ri.Behind)
@@ -446,14 +432,14 @@ func TestCollect(t *testing.T) {
 	t.Run("directory path handling", func(t *testing.T) {
 		ri := collect("/some/deep/project/path")
 		assert.Equal(t, "path", ri.Project)
-		assert.False(t, ri.IsGit)
+		assert.False(t, ri.IsRepo)
 	})
 
 	t.Run("collect with STATUSLINE_FETCH", func(t *testing.T) {
 		t.Setenv("STATUSLINE_FETCH", "1")
 		ri := collect("/tmp")
 		assert.Equal(t, "tmp", ri.Project)
-		assert.False(t, ri.IsGit)
+		assert.False(t, ri.IsRepo)
 	})
 
 	// Test git repository simulation using the actual git directory
@@ -461,7 +447,7 @@ func TestCollect(t *testing.T) {
 		// Use current directory which is a git repo
 		ri := collect(".")
 		assert.Equal(t, "statusline", ri.Project)
-		assert.True(t, ri.IsGit)
+		assert.True(t, ri.IsRepo)
 		// Branch name will depend on actual git state, so just check it's not empty
 		assert.NotEmpty(t, ri.Branch)
 	})
@@ -471,7 +457,7 @@ func TestCollect(t *testing.T) {
 		// Use current directory which is a git repo
 		ri := collect(".")
 		assert.Equal(t, "statusline", ri.Project)
-		assert.True(t, ri.IsGit)
+		assert.True(t, ri.IsRepo)
 		assert.NotEmpty(t, ri.Branch)
 	})
 }
@@ -489,7 +475,7 @@ func TestMainFunction(t *testing.T) {
 		assert.NotPanics(t, func() {
 			// We can't easily test main() directly without refactoring,
 			// but we can test the data flow through render(collect(...))
-			result := render(collect("/tmp"))
+			result := render(collect("/tmp"), input{}, renderOptions{})
 			assert.Contains(t, result, "tmp")
 		})
 	})
@@ -533,9 +519,9 @@ func TestRenderEdgeCases(t *testing.T) {
 			Branch:  "main",
 			Ahead:   3,
 			Behind:  0,
-			IsGit:   true,
+			IsRepo:  true,
 		}
-		result := render(ri)
+		result := render(ri, input{}, renderOptions{Color: true, Theme: themes["default"], Icons: iconSets["unicode"]})
 		assert.Contains(t, result, "↑3")
 		assert.NotContains(t, result, "↓")
 	})
@@ -546,9 +532,9 @@ func TestRenderEdgeCases(t *testing.T) {
 			Branch:  "main",
 			Ahead:   0,
 			Behind:  2,
-			IsGit:   true,
+			IsRepo:  true,
 		}
-		result := render(ri)
+		result := render(ri, input{}, renderOptions{Color: true, Theme: themes["default"], Icons: iconSets["unicode"]})
 		assert.Contains(t, result, "↓2")
 		assert.NotContains(t, result, "↑")
 	})
@@ -616,8 +602,8 @@ func TestGetFetchInterval(t *testing.T) {
 func TestShouldFetch(t *testing.T) {
 	// Generate timestamps for testing
 	now := time.Now()
-	recentTimestamp := now.Add(-10 * time.Minute).Unix()  // 10 minutes ago
-	oldTimestamp := now.Add(-60 * time.Minute).Unix()     // 60 minutes ago
+	recentTimestamp := now.Add(-10 * time.Minute).Unix() // 10 minutes ago
+	oldTimestamp := now.Add(-60 * time.Minute).Unix()    // 60 minutes ago
 
 	tests := []struct {
 		name         string
@@ -741,3 +727,199 @@ func TestFetchLogic(t *testing.T) {
 		})
 	}
 }
+
+func setRunTimeout(t *testing.T, timeout time.Duration, deadline time.Time) {
+	t.Helper()
+	oldTimeout, oldDeadline := runTimeout, runDeadline
+	t.Cleanup(func() { runTimeout, runDeadline = oldTimeout, oldDeadline })
+	runTimeout, runDeadline = timeout, deadline
+}
+
+func TestRunErr(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("success", func(t *testing.T) {
+		out, err := runErr(dir, nil, "git", "--version")
+		require.NoError(t, err)
+		assert.Contains(t, out, "git version")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		setRunTimeout(t, 50*time.Millisecond, time.Time{})
+		_, err := runErr(dir, nil, "sleep", "1")
+		assert.ErrorIs(t, err, errTimeout)
+	})
+
+	t.Run("budget used up", func(t *testing.T) {
+		setRunTimeout(t, time.Second, time.Now().Add(-time.Millisecond))
+		_, err := runErr(dir, nil, "git", "--version")
+		assert.ErrorIs(t, err, errTimeout)
+	})
+
+	t.Run("missing binary", func(t *testing.T) {
+		_, err := runErr(dir, nil, "statusline-no-such-vcs", "status")
+		assert.ErrorIs(t, err, exec.ErrNotFound)
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		_, err := gitErr(dir, "rev-parse", "--show-toplevel")
+		assert.ErrorIs(t, err, errNotRepo)
+	})
+
+	t.Run("other failures keep stderr", func(t *testing.T) {
+		_, err := gitErr(dir, "no-such-command")
+		var exitErr *exec.ExitError
+		assert.True(t, errors.As(err, &exitErr))
+		assert.Contains(t, err.Error(), "no-such-command")
+	})
+}
+
+func TestGitTimeout(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	t.Setenv("STATUSLINE_CACHE", "")
+	t.Setenv("STATUSLINE_FETCH", "")
+	root := testRepo(t)
+	// a file changed just now: git status always runs, but the last state
+	// is kept
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0o644))
+
+	t.Run("root is found without git", func(t *testing.T) {
+		setRunTimeout(t, time.Nanosecond, time.Time{})
+		got, ok := gitBackend{}.Root(filepath.Join(root, ".git"))
+		assert.True(t, ok)
+		assert.Equal(t, root, got)
+	})
+
+	t.Run("placeholder without earlier state", func(t *testing.T) {
+		setRunTimeout(t, time.Nanosecond, time.Time{})
+		ri := gitBackend{}.Collect(root)
+		assert.Equal(t, repoInfo{Branch: branchUnknown, Stale: true}, ri)
+		assert.Equal(t, "p on ⎇ …", render(repoInfo{Project: "p", IsRepo: true, Branch: ri.Branch}, input{}, renderOptions{Icons: iconSets["unicode"]}))
+	})
+
+	t.Run("earlier state", func(t *testing.T) {
+		ri := gitBackend{}.Collect(root)
+		require.Equal(t, "main", ri.Branch)
+
+		setRunTimeout(t, time.Nanosecond, time.Time{})
+		ri = gitBackend{}.Collect(root)
+		assert.Equal(t, "main", ri.Branch)
+		assert.True(t, ri.Stale)
+	})
+
+	t.Run("no state kept with the cache off", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("STATUSLINE_CACHE_DIR", dir)
+		t.Setenv("STATUSLINE_CACHE", "0")
+		require.Equal(t, "main", gitBackend{}.Collect(root).Branch)
+		files, _ := filepath.Glob(filepath.Join(dir, "repo", "*"))
+		assert.Empty(t, files)
+
+		setRunTimeout(t, time.Nanosecond, time.Time{})
+		assert.Equal(t, repoInfo{Branch: branchUnknown, Stale: true}, gitBackend{}.Collect(root))
+	})
+}
+
+func TestCollectTagAndStash(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	setGitOptions(t, untrackedNormal, false)
+	root := testRepo(t)
+	for _, args := range [][]string{
+		{"tag", "v1.0.0"},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "stash", "push", "-q", "-u"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		require.NoError(t, cmd.Run(), args)
+	}
+	backdate(t, root)
+
+	ri := gitBackend{}.Collect(root)
+	assert.Equal(t, "v1.0.0", ri.Tag)
+	assert.Equal(t, 1, ri.Stashes)
+	assert.False(t, ri.HasUntracked, "stashed")
+	assert.Equal(t, 0, stashCount(t.TempDir()))
+}
+
+func TestGitOperation(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"none", nil, ""},
+		{"rebase", map[string]string{"rebase-merge/msgnum": "2\n", "rebase-merge/end": "5\n"}, "rebase 2/5"},
+		{"rebase apply", map[string]string{"rebase-apply/next": "1", "rebase-apply/last": "3"}, "rebase 1/3"},
+		{"am", map[string]string{"rebase-apply/applying": "", "rebase-apply/next": "1", "rebase-apply/last": "3"}, "am 1/3"},
+		{"rebase without steps", map[string]string{"rebase-merge/head-name": "refs/heads/main"}, "rebase"},
+		{"merge", map[string]string{"MERGE_HEAD": "abc"}, "merge"},
+		{"cherry-pick", map[string]string{"CHERRY_PICK_HEAD": "abc"}, "cherry-pick"},
+		{"revert", map[string]string{"REVERT_HEAD": "abc"}, "revert"},
+		{"bisect", map[string]string{"BISECT_LOG": ""}, "bisect"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				p := filepath.Join(dir, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+				require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+			}
+			assert.Equal(t, tt.want, gitOperation(dir))
+		})
+	}
+}
+
+func TestCollectMerge(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	setGitOptions(t, untrackedNormal, false)
+	root := testRepo(t)
+	ident := []string{"-c", "user.name=t", "-c", "user.email=t@example.com"}
+	for _, args := range [][]string{
+		{"add", "a.txt"},
+		{"commit", "-q", "-m", "a"},
+		{"checkout", "-q", "-b", "topic"},
+		{"commit", "-q", "--allow-empty", "-m", "topic"},
+		{"checkout", "-q", "main"},
+		{"commit", "-q", "--allow-empty", "-m", "main"},
+		{"merge", "-q", "--no-commit", "--no-ff", "topic"},
+	} {
+		cmd := exec.Command("git", append(ident, args...)...)
+		cmd.Dir = root
+		require.NoError(t, cmd.Run(), args)
+	}
+	backdate(t, root)
+
+	ri := gitBackend{}.Collect(root)
+	assert.Equal(t, "main", ri.Branch)
+	assert.Equal(t, "merge", ri.Operation)
+}
+
+func TestGatherSurroundings(t *testing.T) {
+	old := containerMarkers
+	t.Cleanup(func() { containerMarkers = old })
+	containerMarkers = nil
+	for _, k := range []string{"VIRTUAL_ENV", "VIRTUAL_ENV_PROMPT", "IN_NIX_SHELL", "DIRENV_DIR", "REMOTE_CONTAINERS", "CODESPACES", "DEVCONTAINER",
+		"AWS_DEFAULT_PROFILE", "AWS_VAULT", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID", "CLOUDSDK_ACTIVE_CONFIG_NAME"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("CONDA_DEFAULT_ENV", "ml")
+	t.Setenv("AWS_PROFILE", "prod-admin")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	t.Setenv("AZURE_CONFIG_DIR", t.TempDir())
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(testKubeconfig), 0o600))
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	var ri repoInfo
+	gatherSurroundings(&ri, parseLines("project,vcs"))
+	assert.Equal(t, repoInfo{}, ri, "nothing the layout doesn't show")
+
+	gatherSurroundings(&ri, parseLines("env,kube|aws,gcp,azure"))
+	assert.Equal(t, []string{"conda:ml"}, ri.Envs)
+	assert.Equal(t, &kubeInfo{Context: "prod-eu", Namespace: "payments"}, ri.Kube)
+	assert.Equal(t, &awsInfo{Profile: "prod-admin", Region: "eu-west-1"}, ri.AWS)
+	assert.Nil(t, ri.GCP)
+	assert.Empty(t, ri.Azure)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// input is the session payload Claude Code writes to stdin.
+type input struct {
+	Cwd       string `json:"cwd"`
+	Workspace struct {
+		CurrentDir string `json:"current_dir"` // where the agent is now
+		ProjectDir string `json:"project_dir"` // where Claude Code was started
+	} `json:"workspace"`
+	Model struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+	} `json:"model"`
+	Cost struct {
+		TotalCostUSD      float64 `json:"total_cost_usd"`
+		TotalDurationMS   int64   `json:"total_duration_ms"`
+		TotalLinesAdded   int     `json:"total_lines_added"`
+		TotalLinesRemoved int     `json:"total_lines_removed"`
+	} `json:"cost"`
+	Exceeds200kTokens bool `json:"exceeds_200k_tokens"`
+	ContextWindow     struct {
+		ContextWindowSize int `json:"context_window_size"`
+		CurrentUsage      struct {
+			InputTokens              int `json:"input_tokens"`
+			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		} `json:"current_usage"`
+	} `json:"context_window"`
+}
+
+func readInput(r io.Reader) input {
+	var in input
+	b, _ := io.ReadAll(r)
+	if len(b) == 0 {
+		return in
+	}
+	if err := json.Unmarshal(b, &in); err != nil {
+		debugf("payload: %v: %s", err, b)
+		return input{}
+	}
+	in.Cwd = strings.TrimSpace(in.Cwd)
+	in.Workspace.CurrentDir = strings.TrimSpace(in.Workspace.CurrentDir)
+	in.Workspace.ProjectDir = strings.TrimSpace(in.Workspace.ProjectDir)
+	return in
+}
+
+// currentDir returns the directory the agent is in.
+func (in input) currentDir() string {
+	if in.Workspace.CurrentDir != "" {
+		return in.Workspace.CurrentDir
+	}
+	return in.Cwd
+}
+
+// contextPercent returns how much of the context window the last request
+// used, when the payload carries context window details.
+func (in input) contextPercent() (int, bool) {
+	cw := in.ContextWindow
+	if cw.ContextWindowSize <= 0 {
+		return 0, false
+	}
+	u := cw.CurrentUsage
+	used := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return used * 100 / cw.ContextWindowSize, true
+}
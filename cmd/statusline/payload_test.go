@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadInput(t *testing.T) {
+	payload := `{
+  "cwd": "/home/user/project",
+  "model": {"id": "claude-opus-4-1", "display_name": "Opus"},
+  "cost": {"total_cost_usd": 0.42, "total_lines_added": 156, "total_lines_removed": 23},
+  "exceeds_200k_tokens": false,
+  "context_window": {
+    "context_window_size": 200000,
+    "current_usage": {"input_tokens": 8500, "cache_creation_input_tokens": 5000, "cache_read_input_tokens": 2000}
+  }
+}`
+	in := readInput(strings.NewReader(payload))
+	assert.Equal(t, "/home/user/project", in.Cwd)
+	assert.Equal(t, "Opus", in.Model.DisplayName)
+	assert.Equal(t, 0.42, in.Cost.TotalCostUSD)
+	assert.Equal(t, 156, in.Cost.TotalLinesAdded)
+
+	pct, ok := in.contextPercent()
+	assert.True(t, ok)
+	assert.Equal(t, 7, pct)
+}
+
+func TestContextPercentMissing(t *testing.T) {
+	_, ok := input{}.contextPercent()
+	assert.False(t, ok)
+}
+
+func TestCurrentDir(t *testing.T) {
+	in := readInput(strings.NewReader(`{"cwd": "/a", "workspace": {"current_dir": " /a/b ", "project_dir": "/a"}}`))
+	assert.Equal(t, "/a/b", in.currentDir())
+	assert.Equal(t, "/a", in.Workspace.ProjectDir)
+
+	in = readInput(strings.NewReader(`{"cwd": "/a"}`))
+	assert.Equal(t, "/a", in.currentDir())
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPluginTimeout bounds a plugin without its own timeout.
+const defaultPluginTimeout = 500 * time.Millisecond
+
+// plugin is a custom segment backed by an external command. The command
+// runs in a shell in the current directory, reads a pluginRequest as JSON on
+// stdin and writes a pluginOutput as JSON on stdout. A plugin with an Expr
+// expression is evaluated in process instead, see eval.
+type plugin struct {
+	Command string   `json:"command"`
+	Expr    string   `json:"expr"`
+	Timeout duration `json:"timeout"` // default 500ms
+	TTL     duration `json:"ttl"`     // reuse the output this long; 0 runs on every render
+}
+
+type pluginRequest struct {
+	Payload input    `json:"payload"`
+	Repo    repoInfo `json:"repo"`
+}
+
+// pluginOutput is what a plugin prints. Colors unset fall back to the theme's
+// plugin style; an empty text hides the segment.
+type pluginOutput struct {
+	Text     string `json:"text"`
+	FG       string `json:"fg"`
+	BG       string `json:"bg"`
+	Bold     bool   `json:"bold"`
+	Priority int    `json:"priority"`
+}
+
+type pluginCache struct {
+	Time   time.Time
+	Output pluginOutput
+}
+
+// duration is a time.Duration written as "500ms" or "5m" in the config.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = duration(v)
+	return err
+}
+
+// runPlugins runs the plugins among names concurrently and returns their
+// output by name. Plugins that fail or time out show their last cached
+// output, if any.
+func runPlugins(plugins map[string]plugin, names []string, dir string, ri repoInfo, in input) map[string]pluginOutput {
+	req, err := json.Marshal(pluginRequest{Payload: in, Repo: ri})
+	if err != nil {
+		return nil
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out = map[string]pluginOutput{}
+	)
+	for _, name := range names {
+		p, ok := plugins[name]
+		if !ok || p.Command == "" && p.Expr == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if o, ok := p.output(name, dir, req); ok {
+				mu.Lock()
+				out[name] = o
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+func (p plugin) output(name, dir string, req []byte) (pluginOutput, bool) {
+	path := cachePath("plugin", name+"\x00"+p.Command+"\x00"+p.Expr+"\x00"+dir)
+	var c pluginCache
+	cached := readCache(path, &c)
+	if cached && time.Since(c.Time) < time.Duration(p.TTL) {
+		return c.Output, true
+	}
+	var (
+		o   pluginOutput
+		err error
+	)
+	if p.Expr != "" {
+		o, err = p.eval(req)
+	} else {
+		o, err = p.run(dir, req)
+	}
+	if err != nil {
+		debugf("plugin %s: %v", name, err)
+		return c.Output, cached
+	}
+	if p.TTL > 0 {
+		writeCache(path, pluginCache{Time: time.Now(), Output: o})
+	}
+	return o, true
+}
+
+func (p plugin) run(dir string, req []byte) (pluginOutput, error) {
+	timeout := time.Duration(p.Timeout)
+	if timeout <= 0 {
+		timeout = defaultPluginTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := shellCommand(ctx, p.Command)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(req)
+	// children of the shell may keep stdout open after it is killed
+	cmd.WaitDelay = 50 * time.Millisecond
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	start := time.Now()
+	b, err := cmd.Output()
+	if debugLog != nil {
+		debugCommand(cmd, time.Since(start), ctx.Err() == context.DeadlineExceeded, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return pluginOutput{}, err
+	}
+	var o pluginOutput
+	err = json.Unmarshal(b, &o)
+	return o, err
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// segment draws the plugin output, filling in unset colors from the theme.
+func (o pluginOutput) segment(opts renderOptions) (segment, bool) {
+	if o.Text == "" {
+		return segment{}, false
+	}
+	st := opts.Theme.Plugin
+	if o.FG != "" {
+		st.FG = o.FG
+	}
+	if o.BG != "" {
+		st.BG = o.BG
+	}
+	st.Bold = st.Bold || o.Bold
+	return segment{Spans: []span{{o.Text, st, ""}}, Block: st, Priority: o.Priority}, true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginConfig(t *testing.T) {
+	var cfg config
+	require.NoError(t, json.Unmarshal([]byte(`{"plugins": {"oncall": {"command": "oncall --short", "timeout": "200ms", "ttl": "5m"}}}`), &cfg))
+	assert.Equal(t, plugin{Command: "oncall --short", Timeout: duration(200 * time.Millisecond), TTL: duration(5 * time.Minute)}, cfg.Plugins["oncall"])
+
+	assert.Error(t, json.Unmarshal([]byte(`{"plugins": {"x": {"ttl": "soon"}}}`), &cfg))
+}
+
+func TestRunPlugins(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	dir := t.TempDir()
+	plugins := map[string]plugin{
+		"ticket": {Command: `cat > request.json; echo '{"text": "PROJ-42", "fg": "39", "priority": 55}'`},
+		"broken": {Command: "echo not json"},
+		"fails":  {Command: "exit 1"},
+		"slow":   {Command: "sleep 2", Timeout: duration(50 * time.Millisecond)},
+		"unused": {Command: "echo '{\"text\": \"x\"}'"},
+	}
+	var in input
+	in.Model.DisplayName = "Opus"
+	ri := repoInfo{Project: "statusline", Branch: "main", IsRepo: true}
+
+	start := time.Now()
+	out := runPlugins(plugins, []string{"ticket", "broken", "fails", "slow"}, dir, ri, in)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, map[string]pluginOutput{"ticket": {Text: "PROJ-42", FG: "39", Priority: 55}}, out)
+
+	b, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	require.NoError(t, err)
+	var req pluginRequest
+	require.NoError(t, json.Unmarshal(b, &req))
+	assert.Equal(t, "Opus", req.Payload.Model.DisplayName)
+	assert.Equal(t, ri, req.Repo)
+}
+
+func TestPluginTTL(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "runs")
+	p := plugin{Command: `test -e fail && exit 1; echo run >> runs; echo '{"text": "on call"}'`, TTL: duration(time.Hour)}
+	plugins := map[string]plugin{"oncall": p}
+
+	for range 3 {
+		out := runPlugins(plugins, []string{"oncall"}, dir, repoInfo{}, input{})
+		assert.Equal(t, "on call", out["oncall"].Text)
+	}
+	b, err := os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(b), "run"))
+
+	// once expired, a failing run falls back to the last output
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fail"), nil, 0o644))
+	plugins["oncall"] = plugin{Command: p.Command, TTL: duration(time.Nanosecond)}
+	out := runPlugins(plugins, []string{"oncall"}, dir, repoInfo{}, input{})
+	assert.Equal(t, "on call", out["oncall"].Text)
+}
+
+func TestPluginSegment(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th, Plugins: map[string]pluginOutput{
+		"ticket": {Text: "PROJ-42", FG: "39", Bold: true, Priority: 55},
+		"empty":  {},
+	}}
+
+	segs := lineSegments([]string{"ticket", "empty", "missing"}, repoInfo{}, input{}, opts)
+	st := style{FG: "39", BG: th.Plugin.BG, Bold: true}
+	assert.Equal(t, []segment{{Spans: []span{{"PROJ-42", st, ""}}, Block: st, Priority: 55}}, segs)
+}
+
+func TestLayoutPlugins(t *testing.T) {
+	plugins := map[string]plugin{"ticket": {Command: "x"}, "oncall": {Command: "y"}, "clock": {Command: "z"}}
+	layout := parseLines("project,ticket|clock")
+	assert.Equal(t, []string{"ticket"}, layoutPlugins(layout, plugins))
+	assert.Empty(t, layoutPlugins(nil, plugins))
+}
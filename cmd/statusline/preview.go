@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// scenario is a made-up session rendered by statusline preview.
+type scenario struct {
+	Name    string
+	Repo    repoInfo
+	Payload string // as Claude Code writes it to stdin
+}
+
+const previewRoot = "/home/dev/src/shop"
+
+// previewRepo is the repository of the scenarios, with edit applied.
+func previewRepo(edit func(*repoInfo)) repoInfo {
+	ri := repoInfo{
+		Project:    "shop",
+		Root:       previewRoot,
+		VCS:        "git",
+		Branch:     "main",
+		IsRepo:     true,
+		Toolchains: map[string]string{"go": "1.24.1", "node": "22.11.0", "python": "3.12.7", "rust": "1.83.0"},
+		Envs:       []string{"venv:shop"},
+		Kube:       &kubeInfo{Context: "staging", Namespace: "shop"},
+		AWS:        &awsInfo{Profile: "shop-dev", Region: "eu-west-1"},
+		GCP:        &gcpInfo{Config: "default", Project: "shop-dev"},
+		Azure:      "Shop Dev",
+	}
+	if edit != nil {
+		edit(&ri)
+	}
+	return ri
+}
+
+// previewPayload is a session in previewRoot that cost usd and whose last
+// request used tokens of a 200k context window.
+func previewPayload(usd float64, tokens int) string {
+	return fmt.Sprintf(`{
+	"cwd": %[1]q,
+	"workspace": {"current_dir": %[1]q, "project_dir": %[1]q},
+	"model": {"id": "claude-sonnet", "display_name": "Sonnet"},
+	"cost": {"total_cost_usd": %[2]g},
+	"context_window": {"context_window_size": 200000, "current_usage": {"input_tokens": %[3]d}}
+}`, previewRoot, usd, tokens)
+}
+
+var scenarios = []scenario{
+	{"clean", previewRepo(nil), previewPayload(0.42, 41000)},
+	{"dirty", previewRepo(func(ri *repoInfo) {
+		ri.Branch = "feature/checkout"
+		ri.HasTracked, ri.HasUntracked = true, true
+	}), previewPayload(1.37, 68000)},
+	// git detaches HEAD while rebasing
+	{"rebase", previewRepo(func(ri *repoInfo) {
+		ri.Branch, ri.Commit = "detached@4f2c9e1", "4f2c9e1"
+		ri.Operation = "rebase 2/5"
+		ri.HasTracked = true
+	}), previewPayload(2.05, 97000)},
+	{"detached", previewRepo(func(ri *repoInfo) {
+		ri.Branch, ri.Commit = "detached@9b1d3a7", "9b1d3a7"
+	}), previewPayload(0.18, 23000)},
+	{"ahead-behind", previewRepo(func(ri *repoInfo) {
+		ri.Ahead, ri.Behind = 3, 2
+	}), previewPayload(0.96, 55000)},
+	{"huge-context", previewRepo(func(ri *repoInfo) {
+		ri.HasTracked = true
+	}), previewPayload(12.87, 188000)},
+}
+
+// runPreview implements `statusline preview`: it renders the built-in
+// scenarios, or a payload file and directory, in every theme with the
+// current configuration.
+func runPreview(args []string, color bool, w io.Writer) int {
+	flags := flag.NewFlagSet("preview", flag.ContinueOnError)
+	themeName := flags.String("theme", "", "render only this theme")
+	name := flags.String("scenario", "", "render only this scenario: "+strings.Join(scenarioNames(), ", "))
+	payload := flags.String("payload", "", "render this payload file instead of the scenarios")
+	dir := flags.String("dir", "", "render the state of this directory instead of the scenarios; defaults to the payload's cwd")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfgDir := configDir()
+	cfg, err := loadConfig(cfgDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "statusline:", err)
+	}
+	cfg.applyVCS()
+	names := previewThemes(cfgDir)
+	if *themeName != "" {
+		if _, err := loadTheme(cfgDir, *themeName); err != nil {
+			fmt.Fprintln(os.Stderr, "statusline:", err)
+			return 2
+		}
+		names = []string{*themeName}
+	}
+
+	cwd, _ := os.Getwd()
+	var sessions []scenario
+	switch {
+	case *payload != "" || *dir != "":
+		s, err := loadSession(*payload, *dir, cwd, cfg, newRenderOptions(cfg, cfgDir, color))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "statusline:", err)
+			return 1
+		}
+		sessions = []scenario{s}
+	case *name != "":
+		i := slices.IndexFunc(scenarios, func(s scenario) bool { return s.Name == *name })
+		if i < 0 {
+			fmt.Fprintf(os.Stderr, "statusline: unknown scenario %q (want %s)\n", *name, strings.Join(scenarioNames(), ", "))
+			return 2
+		}
+		sessions = scenarios[i : i+1]
+	default:
+		sessions = scenarios
+	}
+
+	width := 0
+	for _, n := range names {
+		width = max(width, len(n))
+	}
+	for i, s := range sessions {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, s.Name)
+		in := readInput(strings.NewReader(s.Payload))
+		plugins := runLayoutPlugins(cfg, cfg.Lines, cwd, s.Repo, in)
+		for _, n := range names {
+			c := cfg
+			c.Theme = n
+			opts := newRenderOptions(c, cfgDir, color)
+			opts.Plugins = plugins
+			line := render(s.Repo, in, opts)
+			indent := "\n  " + strings.Repeat(" ", width+2)
+			fmt.Fprintf(w, "  %-*s  %s\n", width, n, strings.ReplaceAll(line, "\n", indent))
+		}
+	}
+	return 0
+}
+
+// loadSession reads the payload file, if any, and collects the state of dir,
+// the payload's directory or cwd, as a render would.
+func loadSession(payload, dir, cwd string, cfg config, opts renderOptions) (scenario, error) {
+	var s scenario
+	if payload != "" {
+		b, err := os.ReadFile(payload)
+		if err != nil {
+			return s, err
+		}
+		s.Payload = string(b)
+	}
+	in := readInput(strings.NewReader(s.Payload))
+	if dir == "" {
+		dir = in.currentDir()
+	}
+	if dir == "" {
+		dir = cwd
+	}
+	s.Name = dir
+	s.Repo = gather(dir, cfg, opts)
+	return s, nil
+}
+
+func scenarioNames() []string {
+	var names []string
+	for _, s := range scenarios {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// previewThemes lists the built-in themes followed by the custom ones in the
+// config directory.
+func previewThemes(dir string) []string {
+	names := slices.Sorted(maps.Keys(themes))
+	files, _ := filepath.Glob(filepath.Join(dir, "themes", "*.json"))
+	for _, f := range files {
+		if n := strings.TrimSuffix(filepath.Base(f), ".json"); !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	return names
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setPreviewEnv points statusline preview at an empty config directory with
+// the given layout and returns the directory.
+func setPreviewEnv(t *testing.T, lines string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("STATUSLINE_CONFIG_DIR", dir)
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	t.Setenv("STATUSLINE_DAEMON", "0")
+	t.Setenv("STATUSLINE_THEME", "")
+	t.Setenv("STATUSLINE_ICONS", "ascii")
+	t.Setenv("STATUSLINE_LINES", lines)
+	t.Setenv("STATUSLINE_WIDTH", "")
+	t.Setenv("COLUMNS", "")
+	return dir
+}
+
+func TestScenarioPayloads(t *testing.T) {
+	for _, s := range scenarios {
+		in := readInput(bytes.NewReader([]byte(s.Payload)))
+		assert.Equal(t, previewRoot, in.currentDir(), s.Name)
+		assert.Equal(t, "Sonnet", in.Model.DisplayName, s.Name)
+	}
+	in := readInput(bytes.NewReader([]byte(previewPayload(12.87, 188000))))
+	pct, ok := in.contextPercent()
+	assert.True(t, ok)
+	assert.Equal(t, 94, pct)
+}
+
+func TestRunPreviewScenario(t *testing.T) {
+	setPreviewEnv(t, "project,vcs,sync;context,cost")
+	var b bytes.Buffer
+	require.Equal(t, 0, runPreview([]string{"-scenario", "ahead-behind", "-theme", "gruvbox"}, false, &b))
+	assert.Equal(t, "ahead-behind\n"+
+		"  gruvbox  shop on br main ^3 v2\n"+
+		"           ctx 27% $0.96\n", b.String())
+}
+
+func TestRunPreviewSurroundings(t *testing.T) {
+	setPreviewEnv(t, "env,kube,aws")
+	var b bytes.Buffer
+	require.Equal(t, 0, runPreview([]string{"-scenario", "clean", "-theme", "default"}, false, &b))
+	assert.Equal(t, "clean\n"+
+		"  default  env: venv:shop k8s: staging:shop cloud: aws:shop-dev eu-west-1\n", b.String())
+}
+
+func TestRunPreviewAll(t *testing.T) {
+	dir := setPreviewEnv(t, "vcs")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "themes"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "themes", "mine.json"), []byte(`{"base": "gruvbox"}`), 0o644))
+
+	var b bytes.Buffer
+	require.Equal(t, 0, runPreview(nil, true, &b))
+	out := b.String()
+	for _, s := range scenarios {
+		assert.Contains(t, out, s.Name+"\n  catppuccin  ")
+	}
+	assert.Contains(t, out, "\n  mine        ")
+	assert.Contains(t, out, "detached@9b1d3a7")
+	assert.Contains(t, out, "rebase 2/5")
+	assert.Contains(t, out, "\x1b[", "colored")
+}
+
+func TestRunPreviewPayload(t *testing.T) {
+	setPreviewEnv(t, "project,vcs;model")
+	root := testRepo(t)
+	payload := filepath.Join(t.TempDir(), "payload.json")
+	require.NoError(t, os.WriteFile(payload, []byte(`{"cwd": "`+root+`", "model": {"display_name": "Opus"}}`), 0o644))
+
+	var b bytes.Buffer
+	require.Equal(t, 0, runPreview([]string{"-payload", payload, "-theme", "default"}, false, &b))
+	assert.Equal(t, root+"\n"+
+		"  default  "+filepath.Base(root)+" on br main\n"+
+		"           model: Opus\n", b.String())
+
+	b.Reset()
+	require.Equal(t, 0, runPreview([]string{"-dir", t.TempDir(), "-theme", "default"}, false, &b))
+	assert.NotContains(t, b.String(), " on br ")
+}
+
+func TestRunPreviewErrors(t *testing.T) {
+	setPreviewEnv(t, "")
+	var b bytes.Buffer
+	assert.Equal(t, 2, runPreview([]string{"-scenario", "nope"}, false, &b))
+	assert.Equal(t, 2, runPreview([]string{"-theme", "nope"}, false, &b))
+	assert.Equal(t, 1, runPreview([]string{"-payload", filepath.Join(t.TempDir(), "missing.json")}, false, &b))
+	assert.Empty(t, b.String())
+}
+
+func TestPreviewThemes(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, []string{"catppuccin", "default", "gruvbox", "monochrome", "solarized"}, previewThemes(dir))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "themes"), 0o755))
+	for _, name := range []string{"mine.json", "gruvbox.json"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "themes", name), []byte(`{}`), 0o644))
+	}
+	assert.Equal(t, []string{"catppuccin", "default", "gruvbox", "monochrome", "solarized", "mine"}, previewThemes(dir))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sub-project display styles.
+const (
+	subProjectPath = "path" // repo/services/api
+	subProjectName = "name" // repo › api
+	subProjectOff  = "off"
+)
+
+// defaultMarkers are the files that make a directory a package of its own.
+var defaultMarkers = []string{"go.mod", "package.json", "Cargo.toml", "pyproject.toml", "BUILD", "BUILD.bazel"}
+
+// findSubProject returns the slash-separated path from root to the nearest
+// directory at or above cwd, below root, that contains one of markers. It
+// returns "" when cwd is not inside such a directory.
+func findSubProject(cwd, root string, markers []string) string {
+	if d, err := filepath.EvalSymlinks(cwd); err == nil {
+		cwd = d
+	}
+	rel, err := filepath.Rel(root, cwd)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	for dir := cwd; dir != root; dir = filepath.Dir(dir) {
+		for _, m := range markers {
+			if _, err := os.Stat(filepath.Join(dir, m)); err == nil {
+				rel, _ := filepath.Rel(root, dir)
+				return filepath.ToSlash(rel)
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSubProject(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	mkdir := func(rel string) string {
+		dir := filepath.Join(root, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		return dir
+	}
+	touch := func(rel string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, filepath.FromSlash(rel)), nil, 0o644))
+	}
+
+	api := mkdir("services/api/internal/handler")
+	mkdir("web/src")
+	mkdir("docs")
+	touch("go.mod")
+	touch("services/api/go.mod")
+	touch("web/package.json")
+
+	tests := []struct {
+		name     string
+		cwd      string
+		markers  []string
+		expected string
+	}{
+		{"nested directory of a package", api, defaultMarkers, "services/api"},
+		{"package directory itself", filepath.Join(root, "web"), defaultMarkers, "web"},
+		{"below package", filepath.Join(root, "web", "src"), defaultMarkers, "web"},
+		{"root marker is not a sub-project", filepath.Join(root, "docs"), defaultMarkers, ""},
+		{"repository root", root, defaultMarkers, ""},
+		{"outside the repository", t.TempDir(), defaultMarkers, ""},
+		{"custom markers", api, []string{"package.json"}, ""},
+		{"custom markers match", filepath.Join(root, "web", "src"), []string{"package.json"}, "web"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, findSubProject(tt.cwd, root, tt.markers))
+		})
+	}
+}
+
+func TestProjectSegmentSubProject(t *testing.T) {
+	ri := repoInfo{Project: "repo", SubProject: "services/api"}
+	opts := renderOptions{Theme: themes["default"], Icons: iconSets["unicode"]}
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{"", "repo/services/api"},
+		{subProjectPath, "repo/services/api"},
+		{subProjectName, "repo › api"},
+		{subProjectOff, "repo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			opts.SubProject = tt.mode
+			assert.Equal(t, tt.expected, render(ri, input{}, opts))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// forge holds the URL templates of a code forge. Templates may use {host},
+// {path}, {branch} and {commit}.
+type forge struct {
+	Type   string `json:"type"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Commit string `json:"commit"`
+}
+
+var forgeTypes = map[string]forge{
+	"github": {
+		Repo:   "https://{host}/{path}",
+		Branch: "https://{host}/{path}/tree/{branch}",
+		Commit: "https://{host}/{path}/commit/{commit}",
+	},
+	"gitlab": {
+		Repo:   "https://{host}/{path}",
+		Branch: "https://{host}/{path}/-/tree/{branch}",
+		Commit: "https://{host}/{path}/-/commit/{commit}",
+	},
+	"bitbucket": {
+		Repo:   "https://{host}/{path}",
+		Branch: "https://{host}/{path}/src/{branch}",
+		Commit: "https://{host}/{path}/commits/{commit}",
+	},
+	"gitea": {
+		Repo:   "https://{host}/{path}",
+		Branch: "https://{host}/{path}/src/branch/{branch}",
+		Commit: "https://{host}/{path}/commit/{commit}",
+	},
+}
+
+var forgeHosts = map[string]string{
+	"github.com":    "github",
+	"gitlab.com":    "gitlab",
+	"bitbucket.org": "bitbucket",
+	"codeberg.org":  "gitea",
+	"gitea.com":     "gitea",
+}
+
+// remote is a repository on a forge, e.g. host "github.com", path "owner/repo".
+type remote struct {
+	Host string
+	Path string
+}
+
+// parseRemoteURL understands https, ssh:// and scp-like (git@host:path)
+// remote URLs.
+func parseRemoteURL(raw string) (remote, bool) {
+	raw = strings.TrimSpace(raw)
+	var r remote
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return remote{}, false
+		}
+		r.Host = u.Host
+		if u.Scheme != "http" && u.Scheme != "https" {
+			// ssh ports say nothing about the web UI
+			r.Host = u.Hostname()
+		}
+		r.Path = u.Path
+	} else {
+		colon := strings.Index(raw, ":")
+		if colon < 0 || strings.Contains(raw[:colon], "/") {
+			return remote{}, false
+		}
+		r.Host = raw[strings.LastIndex(raw[:colon], "@")+1 : colon]
+		r.Path = raw[colon+1:]
+	}
+	r.Host = strings.ToLower(r.Host)
+	r.Path = strings.TrimSuffix(strings.Trim(r.Path, "/"), ".git")
+	if r.Host == "" || r.Path == "" {
+		return remote{}, false
+	}
+	return r, true
+}
+
+// forgeFor returns the templates for r's host. forges from the config are
+// matched first, then well-known hosts, then the host name itself, which
+// covers the usual gitlab.example.com style of self-hosted instance.
+func forgeFor(r remote, forges map[string]forge) (forge, bool) {
+	if f, ok := forges[r.Host]; ok {
+		base := forgeTypes[f.Type]
+		if f.Repo == "" {
+			f.Repo = base.Repo
+		}
+		if f.Branch == "" {
+			f.Branch = base.Branch
+		}
+		if f.Commit == "" {
+			f.Commit = base.Commit
+		}
+		return f, f.Repo != ""
+	}
+	if t, ok := forgeHosts[r.Host]; ok {
+		return forgeTypes[t], true
+	}
+	for _, t := range []string{"github", "gitlab", "bitbucket", "gitea"} {
+		if strings.Contains(r.Host, t) {
+			return forgeTypes[t], true
+		}
+	}
+	if strings.Contains(r.Host, "forgejo") {
+		return forgeTypes["gitea"], true
+	}
+	return forge{}, false
+}
+
+// expand fills in a URL template. Branch names are escaped per path element
+// so that "feature/x" stays a path.
+func (r remote) expand(tmpl, branch, commit string) string {
+	if tmpl == "" {
+		return ""
+	}
+	elems := strings.Split(branch, "/")
+	for i, e := range elems {
+		elems[i] = url.PathEscape(e)
+	}
+	return strings.NewReplacer(
+		"{host}", r.Host,
+		"{path}", r.Path,
+		"{branch}", strings.Join(elems, "/"),
+		"{commit}", url.PathEscape(commit),
+	).Replace(tmpl)
+}
+
+// repoLinks returns the URLs of the project, branch and commit of ri. Any of
+// them is empty when it does not apply or the remote is not recognized;
+// several jj bookmarks are not linked.
+func repoLinks(ri repoInfo, forges map[string]forge) (repo, branch, commit string) {
+	r, ok := parseRemoteURL(ri.Remote)
+	if !ok {
+		return "", "", ""
+	}
+	f, ok := forgeFor(r, forges)
+	if !ok {
+		return "", "", ""
+	}
+	repo = r.expand(f.Repo, "", "")
+	if ri.Commit != "" {
+		commit = r.expand(f.Commit, "", ri.Commit)
+	} else if ri.Branch != "" && ri.Branch != "no-branch" && ri.Branch != branchUnknown && !strings.Contains(ri.Branch, ",") {
+		branch = r.expand(f.Branch, ri.Branch, "")
+	}
+	return repo, branch, commit
+}
+
+// remoteURL returns the URL of the default remote of the repository at dir.
+func remoteURL(dir string) string {
+	return git(dir, "ls-remote", "--get-url")
+}
+
+// hyperlink wraps text in an OSC 8 hyperlink.
+func hyperlink(text, target string) string {
+	if target == "" || text == "" {
+		return text
+	}
+	return esc + "]8;;" + target + esc + "\\" + text + esc + "]8;;" + esc + "\\"
+}
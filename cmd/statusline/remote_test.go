@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected remote
+		ok       bool
+	}{
+		{"https", "https://github.com/owner/repo.git", remote{"github.com", "owner/repo"}, true},
+		{"https without suffix", "https://gitlab.com/group/sub/repo", remote{"gitlab.com", "group/sub/repo"}, true},
+		{"https with user and port", "https://user@git.example.com:8443/team/repo.git", remote{"git.example.com:8443", "team/repo"}, true},
+		{"scp-like ssh", "git@github.com:owner/repo.git", remote{"github.com", "owner/repo"}, true},
+		{"scp-like without user", "bitbucket.org:team/repo.git", remote{"bitbucket.org", "team/repo"}, true},
+		{"ssh url with port", "ssh://git@Codeberg.org:2222/owner/repo.git", remote{"codeberg.org", "owner/repo"}, true},
+		{"trailing slash", "https://github.com/owner/repo/", remote{"github.com", "owner/repo"}, true},
+		{"remote name", "origin", remote{}, false},
+		{"local path", "/srv/git/repo.git", remote{}, false},
+		{"relative path with colon", "../repo:x", remote{}, false},
+		{"empty", "", remote{}, false},
+		{"host only", "https://github.com/", remote{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := parseRemoteURL(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, r)
+		})
+	}
+}
+
+func TestRepoLinks(t *testing.T) {
+	tests := []struct {
+		name                 string
+		ri                   repoInfo
+		forges               map[string]forge
+		repo, branch, commit string
+	}{
+		{
+			name:   "github branch",
+			ri:     repoInfo{Branch: "feature/x y", Remote: "git@github.com:owner/repo.git"},
+			repo:   "https://github.com/owner/repo",
+			branch: "https://github.com/owner/repo/tree/feature/x%20y",
+		},
+		{
+			name:   "gitlab detached commit",
+			ri:     repoInfo{Branch: "detached@abc123", Commit: "abc123", Remote: "https://gitlab.com/group/repo.git"},
+			repo:   "https://gitlab.com/group/repo",
+			commit: "https://gitlab.com/group/repo/-/commit/abc123",
+		},
+		{
+			name:   "bitbucket",
+			ri:     repoInfo{Branch: "main", Remote: "git@bitbucket.org:team/repo.git"},
+			repo:   "https://bitbucket.org/team/repo",
+			branch: "https://bitbucket.org/team/repo/src/main",
+		},
+		{
+			name:   "codeberg is gitea",
+			ri:     repoInfo{Branch: "main", Remote: "https://codeberg.org/owner/repo.git"},
+			repo:   "https://codeberg.org/owner/repo",
+			branch: "https://codeberg.org/owner/repo/src/branch/main",
+		},
+		{
+			name:   "self-hosted guessed from host name",
+			ri:     repoInfo{Branch: "main", Remote: "git@gitlab.example.com:team/repo.git"},
+			repo:   "https://gitlab.example.com/team/repo",
+			branch: "https://gitlab.example.com/team/repo/-/tree/main",
+		},
+		{
+			name:   "configured forge type",
+			ri:     repoInfo{Branch: "main", Remote: "git@code.example.com:team/repo.git"},
+			forges: map[string]forge{"code.example.com": {Type: "gitea"}},
+			repo:   "https://code.example.com/team/repo",
+			branch: "https://code.example.com/team/repo/src/branch/main",
+		},
+		{
+			name: "configured templates",
+			ri:   repoInfo{Branch: "main", Remote: "ssh://git@code.example.com:7999/team/repo.git"},
+			forges: map[string]forge{"code.example.com": {
+				Repo:   "https://code.example.com/projects/{path}",
+				Branch: "https://code.example.com/projects/{path}/browse?at={branch}",
+			}},
+			repo:   "https://code.example.com/projects/team/repo",
+			branch: "https://code.example.com/projects/team/repo/browse?at=main",
+		},
+		{
+			name: "unknown forge",
+			ri:   repoInfo{Branch: "main", Remote: "git@git.example.com:team/repo.git"},
+		},
+		{
+			name: "no remote",
+			ri:   repoInfo{Branch: "main", Remote: "origin"},
+		},
+		{
+			name: "no branch",
+			ri:   repoInfo{Branch: "no-branch", Remote: "https://github.com/owner/repo"},
+			repo: "https://github.com/owner/repo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, branch, commit := repoLinks(tt.ri, tt.forges)
+			assert.Equal(t, tt.repo, repo)
+			assert.Equal(t, tt.branch, branch)
+			assert.Equal(t, tt.commit, commit)
+		})
+	}
+}
+
+func TestHyperlink(t *testing.T) {
+	assert.Equal(t, "\x1b]8;;https://x\x1b\\text\x1b]8;;\x1b\\", hyperlink("text", "https://x"))
+	assert.Equal(t, "text", hyperlink("text", ""))
+	assert.Equal(t, "", hyperlink("", "https://x"))
+}
+
+func TestRenderLinks(t *testing.T) {
+	ri := repoInfo{Project: "repo", Branch: "main", IsRepo: true, Remote: "git@github.com:owner/repo.git"}
+	opts := renderOptions{Color: true, Theme: theme{}, Icons: iconSet{}, Links: true}
+
+	result := render(ri, input{}, opts)
+	expected := "\x1b]8;;https://github.com/owner/repo\x1b\\repo\x1b]8;;\x1b\\ on " +
+		"\x1b]8;;https://github.com/owner/repo/tree/main\x1b\\main\x1b]8;;\x1b\\"
+	assert.Equal(t, expected, result)
+
+	opts.Color = false
+	assert.Equal(t, "repo on main", render(ri, input{}, opts))
+
+	opts.Color, opts.Links = true, false
+	assert.Equal(t, "repo on main", render(ri, input{}, opts))
+}
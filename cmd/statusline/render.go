@@ -0,0 +1,308 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// Rendering modes. Plain draws colored text on the terminal background; the
+// powerline modes give every segment a background block joined by arrow or
+// rounded separators and need a Nerd Font or powerline-patched font.
+const (
+	modePlain     = "plain"
+	modePowerline = "powerline"
+	modeRounded   = "rounded"
+)
+
+const (
+	plSeparator      = "\ue0b0"
+	plRightSeparator = "\ue0b2"
+	roundLeftCap     = "\ue0b6"
+	roundSeparator   = "\ue0b4"
+)
+
+// renderOptions carries the settings resolved once at startup that shape the
+// rendered line.
+type renderOptions struct {
+	Color bool
+	Theme theme
+	Icons iconSet
+	Mode  string
+
+	Links  bool
+	Forges map[string]forge
+
+	Lines []lineLayout
+	Width int // terminal width, 0 when unknown
+
+	SubProject string
+
+	Danger []string // patterns of contexts and profiles drawn in the danger style
+
+	Plugins map[string]pluginOutput // output of the plugins in the layout, by name
+}
+
+// span is a run of text drawn in one style, optionally linking to a URL.
+type span struct {
+	Text  string
+	Style style
+	Link  string
+}
+
+// segment is one block of the line, e.g. the project name or the branch.
+type segment struct {
+	Lead     string // word placed before the segment in plain mode, e.g. "on"
+	Spans    []span
+	Block    style // block colors in the powerline modes
+	Priority int   // segments with lower priority are dropped first
+}
+
+// lineLayout declares the segments of one output line: Segments on the left
+// and Right flush with the right edge. Width overrides the terminal width
+// for this line; the least important segments are dropped until the line
+// fits in it.
+type lineLayout struct {
+	Segments []string `json:"segments"`
+	Right    []string `json:"right"`
+	Width    int      `json:"width"`
+}
+
+var defaultLayout = []lineLayout{{Segments: []string{"project", "dir", "vcs", "sync"}}}
+
+// render draws one line per layout entry. Lines without any segment to show
+// are left out.
+func render(ri repoInfo, in input, opts renderOptions) string {
+	layout := opts.Lines
+	if len(layout) == 0 {
+		layout = defaultLayout
+	}
+	var lines []string
+	for _, l := range layout {
+		left := lineSegments(l.Segments, ri, in, opts)
+		right := lineSegments(l.Right, ri, in, opts)
+		width := l.Width
+		if width <= 0 {
+			width = opts.Width
+		}
+		if line := renderLine(left, right, width, opts); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// layoutUses reports whether any of names appears in layout, which falls back
+// to defaultLayout when empty.
+func layoutUses(layout []lineLayout, names ...string) bool {
+	if len(layout) == 0 {
+		layout = defaultLayout
+	}
+	for _, l := range layout {
+		for _, n := range names {
+			if slices.Contains(l.Segments, n) || slices.Contains(l.Right, n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// layoutPlugins returns the names in layout that refer to plugins.
+func layoutPlugins(layout []lineLayout, plugins map[string]plugin) []string {
+	var names []string
+	for name := range plugins {
+		if _, builtin := segmentFuncs[name]; !builtin && layoutUses(layout, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func lineSegments(names []string, ri repoInfo, in input, opts renderOptions) []segment {
+	var segs []segment
+	for _, name := range names {
+		// names that are not built in refer to plugins; unknown ones have
+		// no output and are skipped
+		seg, ok := opts.Plugins[name].segment(opts)
+		if f, builtin := segmentFuncs[name]; builtin {
+			seg, ok = f(ri, in, opts)
+		}
+		if ok {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+// renderLine joins the left segments and pads the right ones to width,
+// dropping the segment with the lowest priority (the rightmost on ties)
+// while the line does not fit. When the width is unknown, both groups form
+// one left-aligned line.
+func renderLine(left, right []segment, width int, opts renderOptions) string {
+	if width <= 0 {
+		return joinSegments(append(left[:len(left):len(left)], right...), opts)
+	}
+	for {
+		l, r := joinSegments(left, opts), joinRight(right, opts)
+		used := displayWidth(l) + displayWidth(r)
+		if l != "" && r != "" {
+			used++ // at least one space between the groups
+		}
+		if used <= width || len(left)+len(right) <= 1 {
+			return padBetween(l, r, width)
+		}
+
+		all := append(left[:len(left):len(left)], right...)
+		drop := len(all) - 1
+		for i, seg := range all {
+			if seg.Priority <= all[drop].Priority {
+				drop = i
+			}
+		}
+		if drop < len(left) {
+			left = append(left[:drop:drop], left[drop+1:]...)
+		} else {
+			drop -= len(left)
+			right = append(right[:drop:drop], right[drop+1:]...)
+		}
+	}
+}
+
+// padBetween places r flush right of l within width.
+func padBetween(l, r string, width int) string {
+	if r == "" {
+		return l
+	}
+	gap := width - displayWidth(l) - displayWidth(r)
+	if l != "" {
+		gap = max(gap, 1)
+	}
+	return l + strings.Repeat(" ", max(gap, 0)) + r
+}
+
+func joinSegments(segs []segment, opts renderOptions) string {
+	if !opts.Color {
+		return renderPlain(segs, opts)
+	}
+	switch opts.Mode {
+	case modePowerline:
+		return renderPowerline(segs, opts, "", plSeparator)
+	case modeRounded:
+		return renderPowerline(segs, opts, roundLeftCap, roundSeparator)
+	}
+	return renderPlain(segs, opts)
+}
+
+func renderPlain(segs []segment, opts renderOptions) string {
+	var parts []string
+	for i, seg := range segs {
+		if seg.Lead != "" && i > 0 {
+			parts = append(parts, seg.Lead)
+		}
+		for _, sp := range seg.Spans {
+			if sp.Text != "" {
+				parts = append(parts, opts.link(opts.paint(sp.Text, sp.Style), sp.Link))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// renderPowerline draws each segment on its block background. The separator
+// after a segment takes the segment's background as foreground and the next
+// segment's background as background, so the blocks appear to interlock.
+func renderPowerline(segs []segment, opts renderOptions, leftCap, sep string) string {
+	var b strings.Builder
+	if leftCap != "" && len(segs) > 0 {
+		b.WriteString(opts.paintBlock(leftCap, style{FG: segs[0].Block.BG}))
+	}
+	for i, seg := range segs {
+		b.WriteString(opts.blockContent(seg))
+
+		next := style{FG: seg.Block.BG}
+		if i+1 < len(segs) {
+			next.BG = segs[i+1].Block.BG
+		}
+		b.WriteString(opts.paintBlock(sep, next))
+	}
+	return b.String()
+}
+
+// blockContent draws the spans of seg on its block background.
+func (o renderOptions) blockContent(seg segment) string {
+	var b strings.Builder
+	block := seg.Block
+	for _, sp := range seg.Spans {
+		if sp.Text == "" {
+			continue
+		}
+		b.WriteString(o.paintBlock(" ", block))
+		st := sp.Style
+		if st.FG == "" {
+			st.FG = block.FG
+		}
+		st.BG = block.BG
+		b.WriteString(o.link(o.paintBlock(sp.Text, st), sp.Link))
+	}
+	b.WriteString(o.paintBlock(" ", block))
+	return b.String()
+}
+
+// joinRight joins the segments of a right-aligned group. The powerline
+// modes point their separators left so the group ends flush at the edge.
+func joinRight(segs []segment, opts renderOptions) string {
+	if !opts.Color {
+		return renderPlain(segs, opts)
+	}
+	switch opts.Mode {
+	case modePowerline:
+		return renderPowerlineRight(segs, opts, plRightSeparator, "")
+	case modeRounded:
+		return renderPowerlineRight(segs, opts, roundLeftCap, roundSeparator)
+	}
+	return renderPlain(segs, opts)
+}
+
+// renderPowerlineRight mirrors renderPowerline: the separator before a
+// segment takes its background as foreground and the previous segment's
+// background as background.
+func renderPowerlineRight(segs []segment, opts renderOptions, sep, rightCap string) string {
+	var b strings.Builder
+	for i, seg := range segs {
+		prev := style{FG: seg.Block.BG}
+		if i > 0 {
+			prev.BG = segs[i-1].Block.BG
+		}
+		b.WriteString(opts.paintBlock(sep, prev))
+		b.WriteString(opts.blockContent(seg))
+	}
+	if rightCap != "" && len(segs) > 0 {
+		b.WriteString(opts.paintBlock(rightCap, style{FG: segs[len(segs)-1].Block.BG}))
+	}
+	return b.String()
+}
+
+// link wraps s in a hyperlink to target when links are enabled. Like colors,
+// links are escape sequences and are left out when colors are off.
+func (o renderOptions) link(s, target string) string {
+	if !o.Links || !o.Color {
+		return s
+	}
+	return hyperlink(s, target)
+}
+
+// paint wraps s in the escape sequence for st. Backgrounds are left out: the
+// plain line is drawn on the terminal's own background.
+func (o renderOptions) paint(s string, st style) string {
+	st.BG = ""
+	return o.paintBlock(s, st)
+}
+
+// paintBlock wraps s in the escape sequence for st, background included.
+func (o renderOptions) paintBlock(s string, st style) string {
+	code := st.sgr()
+	if !o.Color || code == "" || s == "" {
+		return s
+	}
+	return esc + "[" + code + "m" + s + esc + "[0m"
+}
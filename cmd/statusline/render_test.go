@@ -0,0 +1,210 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineSegments(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th, Icons: iconSets["unicode"]}
+	names := defaultLayout[0].Segments
+
+	t.Run("non-git directory", func(t *testing.T) {
+		segs := lineSegments(names, repoInfo{Project: "myproject"}, input{}, opts)
+		assert.Equal(t, []segment{{Spans: []span{{"myproject", th.Project, ""}}, Block: th.Project, Priority: 100}}, segs)
+	})
+
+	t.Run("dirty repository ahead", func(t *testing.T) {
+		ri := repoInfo{Project: "myproject", Branch: "main", Ahead: 1, IsRepo: true, HasTracked: true}
+		segs := lineSegments(names, ri, input{}, opts)
+		assert.Len(t, segs, 3)
+		assert.Equal(t, "on", segs[1].Lead)
+		assert.Equal(t, []span{{"⎇", th.Tracked, ""}, {"main", th.Branch, ""}}, segs[1].Spans)
+		assert.Equal(t, []span{{"↑1", th.Ahead, ""}}, segs[2].Spans)
+	})
+
+	t.Run("unknown names are skipped", func(t *testing.T) {
+		segs := lineSegments([]string{"nope", "project"}, repoInfo{Project: "p"}, input{}, opts)
+		assert.Len(t, segs, 1)
+	})
+}
+
+func TestRenderLayout(t *testing.T) {
+	ri := repoInfo{Project: "myproject", Branch: "main", Ahead: 2, IsRepo: true}
+	var in input
+	in.Model.DisplayName = "Opus"
+	in.Cost.TotalCostUSD = 1.234
+	opts := renderOptions{Theme: themes["default"], Icons: iconSets["unicode"]}
+
+	t.Run("default layout", func(t *testing.T) {
+		assert.Equal(t, "myproject on ⎇ main ↑2", render(ri, in, opts))
+	})
+
+	t.Run("multiple lines", func(t *testing.T) {
+		opts := opts
+		opts.Lines = []lineLayout{
+			{Segments: []string{"project", "git"}},
+			{Segments: []string{"model", "cost"}},
+		}
+		assert.Equal(t, "myproject on ⎇ main\n◆ Opus $1.23", render(ri, in, opts))
+	})
+
+	t.Run("empty lines are left out", func(t *testing.T) {
+		opts := opts
+		opts.Lines = []lineLayout{
+			{Segments: []string{"context"}},
+			{Segments: []string{"git", "project"}},
+		}
+		assert.Equal(t, "⎇ main myproject", render(ri, in, opts))
+	})
+
+	t.Run("width budget drops low priority segments", func(t *testing.T) {
+		opts := opts
+		opts.Lines = []lineLayout{
+			{Segments: []string{"project", "git", "sync"}, Width: 20},
+			{Segments: []string{"model", "cost"}, Width: 8},
+		}
+		assert.Equal(t, "myproject on ⎇ main\n◆ Opus", render(ri, in, opts))
+	})
+
+	t.Run("last segment is kept even if too wide", func(t *testing.T) {
+		opts := opts
+		opts.Lines = []lineLayout{{Segments: []string{"project", "git"}, Width: 3}}
+		assert.Equal(t, "myproject", render(ri, in, opts))
+	})
+}
+
+func TestRenderLine(t *testing.T) {
+	segs := []segment{
+		{Spans: []span{{"a", style{}, ""}}, Priority: 10},
+		{Spans: []span{{"b", style{}, ""}}, Priority: 5},
+		{Spans: []span{{"c", style{}, ""}}, Priority: 5},
+		{Spans: []span{{"d", style{}, ""}}, Priority: 20},
+	}
+	opts := renderOptions{}
+
+	assert.Equal(t, "a b c d", renderLine(segs, nil, 0, opts))
+	assert.Equal(t, "a b c d", renderLine(segs, nil, 7, opts))
+	assert.Equal(t, "a b d", renderLine(segs, nil, 6, opts))
+	assert.Equal(t, "a d", renderLine(segs, nil, 4, opts))
+	assert.Equal(t, "d", renderLine(segs, nil, 1, opts))
+	assert.Len(t, segs, 4, "input slice is not modified")
+	assert.Equal(t, "c", segs[2].Spans[0].Text)
+}
+
+func TestRenderRightGroup(t *testing.T) {
+	seg := func(text string, prio int) segment {
+		return segment{Spans: []span{{text, style{}, ""}}, Priority: prio}
+	}
+	left := []segment{seg("proj", 100), seg("main", 90)}
+	right := []segment{seg("$1.00", 30), seg("12:00", 20)}
+	opts := renderOptions{}
+
+	t.Run("padded to width", func(t *testing.T) {
+		result := renderLine(left, right, 30, opts)
+		assert.Equal(t, "proj main          $1.00 12:00", result)
+		assert.Equal(t, 30, displayWidth(result))
+	})
+
+	t.Run("unknown width falls back to one left-aligned line", func(t *testing.T) {
+		assert.Equal(t, "proj main $1.00 12:00", renderLine(left, right, 0, opts))
+	})
+
+	t.Run("narrow width drops low priority segments", func(t *testing.T) {
+		assert.Equal(t, "proj main  $1.00", renderLine(left, right, 16, opts))
+		assert.Equal(t, "proj main", renderLine(left, right, 10, opts))
+	})
+
+	t.Run("right group only", func(t *testing.T) {
+		assert.Equal(t, "     12:00", renderLine(nil, right[1:], 10, opts))
+	})
+
+	t.Run("escapes do not count", func(t *testing.T) {
+		opts := renderOptions{Color: true}
+		colored := []segment{{Spans: []span{{"x", style{FG: "1"}, ""}}}}
+		assert.Equal(t, "\x1b[38;5;1mx\x1b[0m   \x1b[38;5;1mx\x1b[0m", renderLine(colored, colored, 5, opts))
+	})
+
+	t.Run("layout with right group", func(t *testing.T) {
+		defer func(f func() time.Time) { now = f }(now)
+		now = func() time.Time { return time.Date(2025, 1, 2, 9, 5, 0, 0, time.UTC) }
+		opts := renderOptions{
+			Theme: themes["default"],
+			Icons: iconSets["unicode"],
+			Lines: []lineLayout{{Segments: []string{"project"}, Right: []string{"clock"}}},
+			Width: 20,
+		}
+		assert.Equal(t, "myproject      09:05", render(repoInfo{Project: "myproject"}, input{}, opts))
+
+		opts.Width = 0
+		assert.Equal(t, "myproject 09:05", render(repoInfo{Project: "myproject"}, input{}, opts))
+	})
+}
+
+func TestRenderPowerlineRight(t *testing.T) {
+	th := theme{Project: style{BG: "2"}, Clock: style{BG: "3"}}
+	opts := renderOptions{
+		Color: true,
+		Theme: th,
+		Mode:  modePowerline,
+		Lines: []lineLayout{{Right: []string{"project", "clock"}}},
+		Width: 20,
+	}
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2025, 1, 2, 9, 5, 0, 0, time.UTC) }
+
+	result := render(repoInfo{Project: "p"}, input{}, opts)
+	expected := "        " +
+		"\x1b[38;5;2m\ue0b2\x1b[0m" +
+		"\x1b[48;5;2m \x1b[0m\x1b[48;5;2mp\x1b[0m\x1b[48;5;2m \x1b[0m" +
+		"\x1b[38;5;3;48;5;2m\ue0b2\x1b[0m" +
+		"\x1b[48;5;3m \x1b[0m\x1b[48;5;3m09:05\x1b[0m\x1b[48;5;3m \x1b[0m"
+	assert.Equal(t, expected, result)
+}
+
+func TestRenderPowerline(t *testing.T) {
+	th := theme{
+		Project: style{FG: "1", BG: "2"},
+		Branch:  style{BG: "3"},
+		Clean:   style{FG: "4"},
+	}
+	ri := repoInfo{Project: "p", Branch: "main", IsRepo: true}
+
+	t.Run("powerline", func(t *testing.T) {
+		result := render(ri, input{}, renderOptions{Color: true, Theme: th, Icons: iconSets["unicode"], Mode: modePowerline})
+		expected := "\x1b[38;5;1;48;5;2m \x1b[0m" +
+			"\x1b[38;5;1;48;5;2mp\x1b[0m" +
+			"\x1b[38;5;1;48;5;2m \x1b[0m" +
+			"\x1b[38;5;2;48;5;3m\x1b[0m" +
+			"\x1b[48;5;3m \x1b[0m" +
+			"\x1b[38;5;4;48;5;3m⎇\x1b[0m" +
+			"\x1b[48;5;3m \x1b[0m" +
+			"\x1b[48;5;3mmain\x1b[0m" +
+			"\x1b[48;5;3m \x1b[0m" +
+			"\x1b[38;5;3m\x1b[0m"
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("rounded caps", func(t *testing.T) {
+		result := render(repoInfo{Project: "p"}, input{}, renderOptions{Color: true, Theme: th, Icons: iconSets["unicode"], Mode: modeRounded})
+		expected := "\x1b[38;5;2m\x1b[0m" +
+			"\x1b[38;5;1;48;5;2m \x1b[0m" +
+			"\x1b[38;5;1;48;5;2mp\x1b[0m" +
+			"\x1b[38;5;1;48;5;2m \x1b[0m" +
+			"\x1b[38;5;2m\x1b[0m"
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("no color falls back to plain", func(t *testing.T) {
+		result := render(ri, input{}, renderOptions{Color: false, Theme: th, Icons: iconSets["unicode"], Mode: modePowerline})
+		assert.Equal(t, "p on ⎇ main", result)
+	})
+
+	t.Run("unknown mode renders plain", func(t *testing.T) {
+		result := render(ri, input{}, renderOptions{Color: true, Theme: th, Icons: iconSets["unicode"], Mode: "fancy"})
+		assert.Equal(t, "\x1b[38;5;1mp\x1b[0m on \x1b[38;5;4m⎇\x1b[0m main", result)
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// maxFingerprintEntries bounds the working tree walk. Larger trees are
+	// not cached: walking them would cost as much as git status.
+	maxFingerprintEntries = 20000
+
+	// racyWindow is how recently a file may have changed for its state to be
+	// cached. Within it, a later change can keep the same mtime.
+	racyWindow = 2 * time.Second
+)
+
+// repoCache is the cached state of a repository.
+type repoCache struct {
+	Key  string
+	Info repoInfo
+}
+
+// gitStateKey fingerprints everything git status reports on: HEAD, the
+// index, the refs (branches and remote-tracking branches for ahead/behind,
+// tags), the config (upstreams), the stash reflog, the operation in progress
+// and the working tree. It returns "" when the state can't be cached:
+// caching is off, the tree is too large or something changed just now.
+func gitStateKey(root string) string {
+	if os.Getenv("STATUSLINE_CACHE") == "0" {
+		return ""
+	}
+	gitDir, commonDir, ok := gitDirs(root)
+	if !ok {
+		return ""
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	h := fnv.New64a()
+	latest := time.Time{}
+	add := func(path string, fi fs.FileInfo) {
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, fi.ModTime().UnixNano(), fi.Size())
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+
+	h.Write(head)
+	h.Write([]byte(gitOperation(gitDir) + "\x00"))
+	for _, p := range []string{
+		filepath.Join(gitDir, "index"),
+		filepath.Join(commonDir, "packed-refs"),
+		filepath.Join(commonDir, "config"),
+		filepath.Join(commonDir, "logs", "refs", "stash"),
+	} {
+		if fi, err := os.Stat(p); err == nil {
+			add(p, fi)
+		}
+	}
+	n := 0
+	walk := func(dir string, skipGit bool) error {
+		return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if skipGit && d.Name() == ".git" {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if n++; n > maxFingerprintEntries {
+				return fs.SkipAll
+			}
+			if fi, err := d.Info(); err == nil {
+				add(p, fi)
+			}
+			return nil
+		})
+	}
+	_ = walk(filepath.Join(commonDir, "refs"), false)
+	_ = walk(root, true)
+	if n > maxFingerprintEntries || time.Since(latest) < racyWindow {
+		return ""
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// gitDirs returns the git directory of the repository at root and the
+// common directory holding refs, which differ for linked worktrees.
+func gitDirs(root string) (gitDir, commonDir string, ok bool) {
+	gitDir = filepath.Join(root, ".git")
+	fi, err := os.Stat(gitDir)
+	if err != nil {
+		return "", "", false
+	}
+	if !fi.IsDir() {
+		// worktrees and submodules have a "gitdir: <path>" file
+		b, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", "", false
+		}
+		dir, found := strings.CutPrefix(strings.TrimSpace(string(b)), "gitdir:")
+		if !found {
+			return "", "", false
+		}
+		gitDir = strings.TrimSpace(dir)
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(root, gitDir)
+		}
+	}
+	commonDir = gitDir
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(b))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	return gitDir, commonDir, true
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backdateTime is one fixed time, so that backdating twice leaves the
+// fingerprint unchanged.
+var backdateTime = time.Now().Add(-time.Hour).Truncate(time.Second)
+
+// backdate moves every mtime below dir out of the racy window.
+func backdate(t *testing.T, dir string) {
+	t.Helper()
+	old := backdateTime
+	require.NoError(t, filepath.WalkDir(dir, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(p, old, old)
+	}))
+}
+
+func testRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if d, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = d
+	}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		require.NoError(t, cmd.Run())
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644))
+	backdate(t, dir)
+	return dir
+}
+
+func TestGitStateKey(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE", "")
+	root := testRepo(t)
+
+	key := gitStateKey(root)
+	require.NotEmpty(t, key)
+	assert.Equal(t, key, gitStateKey(root))
+
+	t.Run("modified file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("bb"), 0o644))
+		assert.Empty(t, gitStateKey(root), "just changed")
+		backdate(t, root)
+		assert.NotEqual(t, key, gitStateKey(root))
+		key = gitStateKey(root)
+	})
+
+	t.Run("new ref", func(t *testing.T) {
+		cmd := exec.Command("git", "branch", "topic")
+		cmd.Dir = root
+		require.NoError(t, cmd.Run())
+		backdate(t, root)
+		assert.NotEqual(t, key, gitStateKey(root))
+		key = gitStateKey(root)
+	})
+
+	t.Run("upstream", func(t *testing.T) {
+		cmd := exec.Command("git", "branch", "--set-upstream-to=topic")
+		cmd.Dir = root
+		require.NoError(t, cmd.Run())
+		backdate(t, root)
+		assert.NotEqual(t, key, gitStateKey(root), "only .git/config changed")
+		key = gitStateKey(root)
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("ref: refs/heads/topic\n"), 0o644))
+		backdate(t, root)
+		assert.NotEqual(t, key, gitStateKey(root))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("STATUSLINE_CACHE", "0")
+		assert.Empty(t, gitStateKey(root))
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		assert.Empty(t, gitStateKey(t.TempDir()))
+	})
+}
+
+func TestGitDirsWorktree(t *testing.T) {
+	root := testRepo(t)
+	wt := filepath.Join(t.TempDir(), "wt")
+	cmd := exec.Command("git", "worktree", "add", "-q", wt)
+	cmd.Dir = root
+	require.NoError(t, cmd.Run())
+
+	gitDir, commonDir, ok := gitDirs(wt)
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(root, ".git", "worktrees", "wt"), gitDir)
+	assert.Equal(t, filepath.Join(root, ".git"), filepath.Clean(commonDir))
+}
+
+func TestGitCollectUsesCache(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	t.Setenv("STATUSLINE_CACHE", "")
+	t.Setenv("STATUSLINE_FETCH", "")
+	root := testRepo(t)
+
+	ri := gitBackend{}.Collect(root)
+	assert.Equal(t, "main", ri.Branch)
+	assert.True(t, ri.HasUntracked)
+	// the first statuses write the untracked cache into the index, and
+	// only then does the state settle
+	for range 2 {
+		backdate(t, root)
+		gitBackend{}.Collect(root)
+	}
+
+	// a render with unchanged state is answered from the cache
+	var c repoCache
+	require.True(t, readCache(cachePath("repo", root), &c))
+	c.Info.Branch = "from-cache"
+	writeCache(cachePath("repo", root), c)
+	assert.Equal(t, "from-cache", gitBackend{}.Collect(root).Branch)
+
+	require.NoError(t, os.Remove(filepath.Join(root, "a.txt")))
+	backdate(t, root)
+	ri = gitBackend{}.Collect(root)
+	assert.Equal(t, "main", ri.Branch)
+	assert.False(t, ri.HasUntracked)
+}
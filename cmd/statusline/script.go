@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/expr-lang/expr"
+)
+
+// eval evaluates the plugin's Expr expression (https://expr-lang.org) in
+// process. It sees the same payload and repo as a plugin command, e.g.
+//
+//	repo.branch startsWith "release/" ? {"text": "release", "fg": "208"} : ""
+//
+// and returns either the text or an object in the plugin output format.
+func (p plugin) eval(req []byte) (pluginOutput, error) {
+	var env map[string]any
+	if err := json.Unmarshal(req, &env); err != nil {
+		return pluginOutput{}, err
+	}
+	v, err := expr.Eval(p.Expr, env)
+	if err != nil {
+		return pluginOutput{}, err
+	}
+	switch v := v.(type) {
+	case nil:
+		return pluginOutput{}, nil
+	case string:
+		return pluginOutput{Text: v}, nil
+	case map[string]any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return pluginOutput{}, err
+		}
+		var o pluginOutput
+		err = json.Unmarshal(b, &o)
+		return o, err
+	}
+	return pluginOutput{}, fmt.Errorf("expression returned %T, want string or map", v)
+}
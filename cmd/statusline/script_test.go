@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginEval(t *testing.T) {
+	var in input
+	in.Model.DisplayName = "Opus"
+	in.Cost.TotalCostUSD = 12.5
+	req, err := json.Marshal(pluginRequest{Payload: in, Repo: repoInfo{Branch: "release/1.2", Ahead: 3, IsRepo: true}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected pluginOutput
+		wantErr  bool
+	}{
+		{"text", `upper(payload.model.display_name)`, pluginOutput{Text: "OPUS"}, false},
+		{"conditional map", `repo.branch startsWith "release/" ? {"text": "release", "fg": "208", "priority": 70} : ""`,
+			pluginOutput{Text: "release", FG: "208", Priority: 70}, false},
+		{"nothing to show", `repo.ahead > 10 ? "far ahead" : ""`, pluginOutput{}, false},
+		{"formatting", `payload.cost.total_cost_usd > 10 ? {"text": "$" + string(int(payload.cost.total_cost_usd)), "bold": true} : nil`,
+			pluginOutput{Text: "$12", Bold: true}, false},
+		{"missing field is nil", `repo.nope ?? "none"`, pluginOutput{Text: "none"}, false},
+		{"wrong type", `repo.ahead`, pluginOutput{}, true},
+		{"syntax error", `repo.branch +`, pluginOutput{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := plugin{Expr: tt.expr}.eval(req)
+			assert.Equal(t, tt.expected, o)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRunPluginsExpr(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	plugins := map[string]plugin{"branchkind": {Expr: `repo.branch == "main" ? "trunk" : "topic"`}}
+	out := runPlugins(plugins, []string{"branchkind"}, t.TempDir(), repoInfo{Branch: "main"}, input{})
+	assert.Equal(t, map[string]pluginOutput{"branchkind": {Text: "trunk"}}, out)
+}
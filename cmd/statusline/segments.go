@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// segmentFunc builds a segment from the collected repository state and the
+// session payload. It reports false when there is nothing to show.
+type segmentFunc func(ri repoInfo, in input, opts renderOptions) (segment, bool)
+
+// segmentFuncs maps the segment names usable in a layout to their builders.
+var segmentFuncs = map[string]segmentFunc{
+	"project": projectSegment,
+	"dir":     dirSegment,
+	"vcs":     vcsSegment,
+	"git":     vcsSegment, // former name of vcs
+	"sync":    syncSegment,
+	"model":   modelSegment,
+	"context": contextSegment,
+	"cost":    costSegment,
+	"clock":   clockSegment,
+	"env":     envSegment,
+	"kube":    kubeSegment,
+	"aws":     awsSegment,
+	"gcp":     gcpSegment,
+	"azure":   azureSegment,
+	"go":      toolchainSegment("go", func(ic iconSet) string { return ic.Go }),
+	"node":    toolchainSegment("node", func(ic iconSet) string { return ic.Node }),
+	"python":  toolchainSegment("python", func(ic iconSet) string { return ic.Python }),
+	"rust":    toolchainSegment("rust", func(ic iconSet) string { return ic.Rust }),
+}
+
+// now is replaced in tests.
+var now = time.Now
+
+func projectSegment(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+	if ri.Project == "" {
+		return segment{}, false
+	}
+	var repoURL string
+	if opts.Links {
+		repoURL, _, _ = repoLinks(ri, opts.Forges)
+	}
+	th := opts.Theme
+	spans := []span{{ri.Project, th.Project, repoURL}}
+	if ri.SubProject != "" {
+		switch opts.SubProject {
+		case subProjectName:
+			spans = append(spans,
+				span{opts.Icons.Nested, th.Project, ""},
+				span{path.Base(ri.SubProject), th.Project, ""})
+		case subProjectOff:
+		default:
+			spans[0].Text += "/" + ri.SubProject
+		}
+	}
+	return segment{Spans: spans, Block: th.Project, Priority: 100}, true
+}
+
+// dirSegment shows where the agent is relative to the directory Claude Code
+// was started in: the relative path when inside it, a warning and the path
+// when it has left it, nothing when it is still there. Inside a sub-project
+// the project segment shows, only the path below the sub-project is left.
+func dirSegment(ri repoInfo, in input, opts renderOptions) (segment, bool) {
+	cur, proj := in.currentDir(), in.Workspace.ProjectDir
+	if cur == "" || proj == "" {
+		return segment{}, false
+	}
+	rel, err := filepath.Rel(proj, cur)
+	if err != nil || rel == "." {
+		return segment{}, false
+	}
+	if below, ok := belowSubProject(ri, cur, proj, opts); ok {
+		rel = below
+		if rel == "." {
+			return segment{}, false
+		}
+	}
+	th := opts.Theme
+	if isOutside(rel) {
+		return segment{
+			Spans:    []span{{opts.Icons.Outside, th.Warning, ""}, {tildePath(cur), th.Warning, ""}},
+			Block:    th.Dir,
+			Priority: 95,
+		}, true
+	}
+	return segment{Spans: []span{{filepath.ToSlash(rel), th.Dir, ""}}, Block: th.Dir, Priority: 70}, true
+}
+
+// belowSubProject returns the path of cur inside the sub-project when the
+// project segment shows it and proj is at or above it, so dirSegment doesn't
+// repeat it.
+func belowSubProject(ri repoInfo, cur, proj string, opts renderOptions) (string, bool) {
+	if ri.SubProject == "" || opts.SubProject == subProjectOff || !layoutUses(opts.Lines, "project") {
+		return "", false
+	}
+	// the root is symlink-resolved like findSubProject does with cwd
+	for _, p := range []*string{&cur, &proj} {
+		if d, err := filepath.EvalSymlinks(*p); err == nil {
+			*p = d
+		}
+	}
+	sub := filepath.Join(ri.Root, filepath.FromSlash(ri.SubProject))
+	below, err := filepath.Rel(sub, cur)
+	if err != nil || isOutside(below) {
+		return "", false
+	}
+	if above, err := filepath.Rel(proj, sub); err != nil || isOutside(above) {
+		return "", false
+	}
+	return below, true
+}
+
+// isOutside reports whether the relative path rel leads out of its base.
+func isOutside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// tildePath abbreviates the home directory in p to "~".
+func tildePath(p string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return p
+	}
+	if p == home {
+		return "~"
+	}
+	if strings.HasPrefix(p, home+string(filepath.Separator)) {
+		return "~" + p[len(home):]
+	}
+	return p
+}
+
+// vcsSegment shows, after an icon colored by working tree state: the jj
+// change id, the branch (jj bookmarks), the git operation in progress, the
+// git tag at HEAD, the number of git stashes, a "?" when untracked files
+// were not looked for, the hg/sl phase unless it is public and the jj
+// conflict marker.
+func vcsSegment(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+	if !ri.IsRepo {
+		return segment{}, false
+	}
+	var branchURL, commitURL string
+	if opts.Links {
+		_, branchURL, commitURL = repoLinks(ri, opts.Forges)
+	}
+	th, icons := opts.Theme, opts.Icons
+	iconStyle := th.Clean
+	switch {
+	case ri.HasUntracked:
+		iconStyle = th.Untracked
+	case ri.HasTracked:
+		iconStyle = th.Tracked
+	}
+	spans := []span{{icons.Branch, iconStyle, ""}}
+	if ri.ChangeID != "" {
+		spans = append(spans, span{ri.ChangeID, th.Branch, ""})
+	}
+	if ri.Branch != "" {
+		spans = append(spans, span{shorten(ri.Branch, maxBranchLen), th.Branch, branchURL + commitURL})
+	}
+	if ri.Operation != "" {
+		spans = append(spans, span{ri.Operation, th.Warning, ""})
+	}
+	if ri.Tag != "" {
+		spans = append(spans, span{icons.Tag + ri.Tag, th.Branch, ""})
+	}
+	if ri.Stashes > 0 {
+		spans = append(spans, span{fmt.Sprintf("%s%d", icons.Stash, ri.Stashes), th.Branch, ""})
+	}
+	if ri.UntrackedUnknown {
+		// untracked files were not looked for
+		spans = append(spans, span{"?", th.Untracked, ""})
+	}
+	if ri.Phase != "" && ri.Phase != "public" {
+		spans = append(spans, span{ri.Phase, th.Phase, ""})
+	}
+	if ri.Conflict {
+		spans = append(spans, span{icons.Conflict, th.Untracked, ""})
+	}
+	return segment{Lead: "on", Spans: spans, Block: th.Branch, Priority: 90}, true
+}
+
+func syncSegment(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+	th, icons := opts.Theme, opts.Icons
+	var arrows []span
+	if ri.Ahead > 0 {
+		arrows = append(arrows, span{fmt.Sprintf("%s%d", icons.Ahead, ri.Ahead), th.Ahead, ""})
+	}
+	if ri.Behind > 0 {
+		arrows = append(arrows, span{fmt.Sprintf("%s%d", icons.Behind, ri.Behind), th.Behind, ""})
+	}
+	if len(arrows) == 0 {
+		return segment{}, false
+	}
+	return segment{Spans: arrows, Block: th.Ahead, Priority: 50}, true
+}
+
+func modelSegment(_ repoInfo, in input, opts renderOptions) (segment, bool) {
+	name := in.Model.DisplayName
+	if name == "" {
+		name = in.Model.ID
+	}
+	if name == "" {
+		return segment{}, false
+	}
+	th := opts.Theme
+	return segment{
+		Spans:    []span{{opts.Icons.Model, th.Model, ""}, {name, th.Model, ""}},
+		Block:    th.Model,
+		Priority: 40,
+	}, true
+}
+
+// contextWarnPercent is the context window usage from which the context
+// segment is drawn in the warning style.
+const contextWarnPercent = 80
+
+// contextSegment shows how full the context window is. Payloads without
+// context window details only tell whether 200k tokens were exceeded.
+func contextSegment(_ repoInfo, in input, opts renderOptions) (segment, bool) {
+	th := opts.Theme
+	var text string
+	st := th.Context
+	if pct, ok := in.contextPercent(); ok {
+		text = fmt.Sprintf("ctx %d%%", pct)
+		if pct >= contextWarnPercent {
+			st = th.Warning
+		}
+	} else if in.Exceeds200kTokens {
+		text = "ctx >200k"
+		st = th.Warning
+	} else {
+		return segment{}, false
+	}
+	return segment{Spans: []span{{text, st, ""}}, Block: th.Context, Priority: 60}, true
+}
+
+// envSegment shows the virtualenv, conda, nix or direnv environment and
+// whether the session runs in a container.
+func envSegment(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+	if len(ri.Envs) == 0 {
+		return segment{}, false
+	}
+	th := opts.Theme
+	spans := []span{{opts.Icons.Env, th.Env, ""}}
+	for _, e := range ri.Envs {
+		spans = append(spans, span{e, th.Env, ""})
+	}
+	return segment{Spans: spans, Block: th.Env, Priority: 45}, true
+}
+
+// kubeSegment shows the current Kubernetes context and namespace, in the
+// danger style when the context matches one of the danger patterns.
+func kubeSegment(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+	k := ri.Kube
+	if k == nil {
+		return segment{}, false
+	}
+	th := opts.Theme
+	st := th.Kube
+	if matchesAny(opts.Danger, k.Context) {
+		st = th.Danger
+	}
+	return segment{
+		Spans:    []span{{opts.Icons.Kube, st, ""}, {k.Context + ":" + k.Namespace, st, ""}},
+		Block:    st,
+		Priority: 85,
+	}, true
+}
+
+// awsSegment shows the AWS profile and region.
+func awsSegment(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+	a := ri.AWS
+	if a == nil {
+		return segment{}, false
+	}
+	text := "aws"
+	if a.Profile != "" {
+		text += ":" + a.Profile
+	}
+	return cloudSegment(opts, []string{a.Profile}, text, a.Region), true
+}
+
+// gcpSegment shows the project of the active gcloud configuration, or the
+// configuration name when it has no project. Both are matched against the
+// danger patterns.
+func gcpSegment(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+	g := ri.GCP
+	if g == nil {
+		return segment{}, false
+	}
+	project := g.Project
+	if project == "" {
+		project = g.Config
+	}
+	return cloudSegment(opts, []string{g.Config, project}, "gcp:"+project), true
+}
+
+// azureSegment shows the default Azure subscription.
+func azureSegment(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+	if ri.Azure == "" {
+		return segment{}, false
+	}
+	return cloudSegment(opts, []string{ri.Azure}, "az:"+ri.Azure), true
+}
+
+// cloudSegment draws texts in the cloud style, or the danger style when one
+// of names matches one of the danger patterns.
+func cloudSegment(opts renderOptions, names []string, texts ...string) segment {
+	th := opts.Theme
+	st := th.Cloud
+	for _, n := range names {
+		if n != "" && matchesAny(opts.Danger, n) {
+			st = th.Danger
+		}
+	}
+	spans := []span{{opts.Icons.Cloud, st, ""}}
+	for _, t := range texts {
+		spans = append(spans, span{t, st, ""})
+	}
+	return segment{Spans: spans, Block: st, Priority: 80}
+}
+
+// toolchainSegment returns the builder of the segment showing the version of
+// toolchain name, as detected by detectToolchains.
+func toolchainSegment(name string, icon func(iconSet) string) segmentFunc {
+	return func(ri repoInfo, _ input, opts renderOptions) (segment, bool) {
+		v := ri.Toolchains[name]
+		if v == "" {
+			return segment{}, false
+		}
+		th := opts.Theme
+		return segment{
+			Spans:    []span{{icon(opts.Icons), th.Toolchain, ""}, {v, th.Toolchain, ""}},
+			Block:    th.Toolchain,
+			Priority: 35,
+		}, true
+	}
+}
+
+func costSegment(_ repoInfo, in input, opts renderOptions) (segment, bool) {
+	if in.Cost.TotalCostUSD <= 0 {
+		return segment{}, false
+	}
+	th := opts.Theme
+	text := fmt.Sprintf("$%.2f", in.Cost.TotalCostUSD)
+	return segment{Spans: []span{{text, th.Cost, ""}}, Block: th.Cost, Priority: 30}, true
+}
+
+func clockSegment(_ repoInfo, _ input, opts renderOptions) (segment, bool) {
+	th := opts.Theme
+	return segment{Spans: []span{{now().Format("15:04"), th.Clock, ""}}, Block: th.Clock, Priority: 20}, true
+}
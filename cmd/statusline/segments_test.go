@@ -0,0 +1,200 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelSegment(t *testing.T) {
+	opts := renderOptions{Theme: themes["default"], Icons: iconSets["ascii"]}
+
+	var in input
+	_, ok := modelSegment(repoInfo{}, in, opts)
+	assert.False(t, ok)
+
+	in.Model.ID = "claude-opus-4-1"
+	seg, ok := modelSegment(repoInfo{}, in, opts)
+	assert.True(t, ok)
+	assert.Equal(t, "claude-opus-4-1", seg.Spans[1].Text)
+
+	in.Model.DisplayName = "Opus"
+	seg, _ = modelSegment(repoInfo{}, in, opts)
+	assert.Equal(t, "Opus", seg.Spans[1].Text)
+}
+
+func TestContextSegment(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th}
+
+	tests := []struct {
+		name     string
+		size     int
+		used     int
+		exceeds  bool
+		ok       bool
+		expected span
+	}{
+		{name: "no context details", ok: false},
+		{name: "percentage", size: 200000, used: 50000, ok: true, expected: span{"ctx 25%", th.Context, ""}},
+		{name: "high usage warns", size: 200000, used: 170000, ok: true, expected: span{"ctx 85%", th.Warning, ""}},
+		{name: "exceeds 200k only", exceeds: true, ok: true, expected: span{"ctx >200k", th.Warning, ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in input
+			in.ContextWindow.ContextWindowSize = tt.size
+			in.ContextWindow.CurrentUsage.InputTokens = tt.used
+			in.Exceeds200kTokens = tt.exceeds
+			seg, ok := contextSegment(repoInfo{}, in, opts)
+			assert.Equal(t, tt.ok, ok)
+			if ok {
+				assert.Equal(t, []span{tt.expected}, seg.Spans)
+			}
+		})
+	}
+}
+
+func TestCostSegment(t *testing.T) {
+	opts := renderOptions{Theme: themes["default"]}
+
+	var in input
+	_, ok := costSegment(repoInfo{}, in, opts)
+	assert.False(t, ok)
+
+	in.Cost.TotalCostUSD = 0.015
+	seg, ok := costSegment(repoInfo{}, in, opts)
+	assert.True(t, ok)
+	assert.Equal(t, "$0.01", seg.Spans[0].Text)
+}
+
+func TestVCSSegmentJJ(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th, Icons: iconSets["unicode"]}
+
+	ri := repoInfo{IsRepo: true, VCS: "jj", ChangeID: "kx", Empty: true}
+	seg, ok := vcsSegment(ri, input{}, opts)
+	assert.True(t, ok)
+	assert.Equal(t, []span{{"⎇", th.Clean, ""}, {"kx", th.Branch, ""}}, seg.Spans)
+
+	ri = repoInfo{IsRepo: true, VCS: "jj", ChangeID: "kx", Branch: "main", HasTracked: true, Conflict: true}
+	seg, _ = vcsSegment(ri, input{}, opts)
+	assert.Equal(t, []span{
+		{"⎇", th.Tracked, ""},
+		{"kx", th.Branch, ""},
+		{"main", th.Branch, ""},
+		{"✘", th.Untracked, ""},
+	}, seg.Spans)
+}
+
+func TestVCSSegmentPhase(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th, Icons: iconSets["unicode"]}
+
+	seg, _ := vcsSegment(repoInfo{IsRepo: true, VCS: "hg", Branch: "default", Phase: "draft"}, input{}, opts)
+	assert.Equal(t, []span{{"⎇", th.Clean, ""}, {"default", th.Branch, ""}, {"draft", th.Phase, ""}}, seg.Spans)
+
+	seg, _ = vcsSegment(repoInfo{IsRepo: true, VCS: "hg", Branch: "default", Phase: "public"}, input{}, opts)
+	assert.Len(t, seg.Spans, 2)
+}
+
+func TestVCSSegmentOperation(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th, Icons: iconSets["unicode"]}
+
+	seg, _ := vcsSegment(repoInfo{IsRepo: true, Branch: "detached@4f2c9e1", Operation: "rebase 2/5", HasTracked: true}, input{}, opts)
+	assert.Equal(t, []span{{"⎇", th.Tracked, ""}, {"detached@4f2c9e1", th.Branch, ""}, {"rebase 2/5", th.Warning, ""}}, seg.Spans)
+}
+
+func TestDirSegment(t *testing.T) {
+	th := themes["default"]
+	opts := renderOptions{Theme: th, Icons: iconSets["unicode"]}
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		current  string
+		project  string
+		ok       bool
+		expected []span
+	}{
+		{name: "no workspace", ok: false},
+		{name: "in project dir", current: "/src/app", project: "/src/app", ok: false},
+		{name: "trailing slash", current: "/src/app/", project: "/src/app", ok: false},
+		{
+			name: "inside", current: "/src/app/api/internal", project: "/src/app", ok: true,
+			expected: []span{{"api/internal", th.Dir, ""}},
+		},
+		{
+			name: "outside", current: "/etc", project: "/src/app", ok: true,
+			expected: []span{{"⚠", th.Warning, ""}, {"/etc", th.Warning, ""}},
+		},
+		{
+			name: "parent", current: "/src", project: "/src/app", ok: true,
+			expected: []span{{"⚠", th.Warning, ""}, {"/src", th.Warning, ""}},
+		},
+		{
+			name: "sibling with common prefix", current: "/src/app2", project: "/src/app", ok: true,
+			expected: []span{{"⚠", th.Warning, ""}, {"/src/app2", th.Warning, ""}},
+		},
+		{
+			name: "outside in home", current: filepath.Join(home, "other"), project: "/src/app", ok: true,
+			expected: []span{{"⚠", th.Warning, ""}, {"~" + string(filepath.Separator) + "other", th.Warning, ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in input
+			in.Workspace.CurrentDir = tt.current
+			in.Workspace.ProjectDir = tt.project
+			seg, ok := dirSegment(repoInfo{}, in, opts)
+			assert.Equal(t, tt.ok, ok)
+			if ok {
+				assert.Equal(t, tt.expected, seg.Spans)
+			}
+		})
+	}
+}
+
+func TestDirSegmentFallsBackToCwd(t *testing.T) {
+	var in input
+	in.Cwd = "/src/app/web"
+	in.Workspace.ProjectDir = "/src/app"
+	seg, ok := dirSegment(repoInfo{}, in, renderOptions{Icons: iconSets["unicode"]})
+	assert.True(t, ok)
+	assert.Equal(t, "web", seg.Spans[0].Text)
+}
+
+func TestDirSegmentBelowSubProject(t *testing.T) {
+	opts := renderOptions{Icons: iconSets["unicode"]}
+	ri := repoInfo{Project: "repo", Root: "/src/repo", SubProject: "services/api", IsRepo: true}
+	in := func(cur, proj string) input {
+		var in input
+		in.Workspace.CurrentDir, in.Workspace.ProjectDir = cur, proj
+		return in
+	}
+
+	assert.Equal(t, "repo/services/api on ⎇", render(ri, in("/src/repo/services/api", "/src/repo"), opts),
+		"the sub-project is not repeated")
+	assert.Equal(t, "repo/services/api internal on ⎇", render(ri, in("/src/repo/services/api/internal", "/src/repo"), opts))
+
+	seg, ok := dirSegment(ri, in("/src/repo/services/api/internal", "/src/repo/services/api/internal"), opts)
+	assert.False(t, ok)
+	seg, ok = dirSegment(ri, in("/src/repo/services/api/internal/x", "/src/repo/services/api/internal"), opts)
+	require.True(t, ok, "started below the sub-project")
+	assert.Equal(t, "x", seg.Spans[0].Text)
+
+	opts.SubProject = subProjectOff
+	seg, _ = dirSegment(ri, in("/src/repo/services/api", "/src/repo"), opts)
+	assert.Equal(t, "services/api", seg.Spans[0].Text)
+
+	opts.SubProject, opts.Lines = "", parseLines("dir,vcs")
+	seg, _ = dirSegment(ri, in("/src/repo/services/api", "/src/repo"), opts)
+	assert.Equal(t, "services/api", seg.Spans[0].Text, "no project segment to show it")
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// style describes how a segment is drawn. Colors are 256-color indexes ("82")
+// or hex RGB ("#a6e3a1"); empty means the terminal default. BG is only drawn
+// by renderers that give segments a background block.
+type style struct {
+	FG   string `json:"fg,omitempty"`
+	BG   string `json:"bg,omitempty"`
+	Bold bool   `json:"bold,omitempty"`
+}
+
+// theme holds the style of every segment.
+type theme struct {
+	Project   style `json:"project"`
+	Dir       style `json:"dir"`
+	Branch    style `json:"branch"`
+	Clean     style `json:"clean"`
+	Tracked   style `json:"tracked"`
+	Untracked style `json:"untracked"`
+	Ahead     style `json:"ahead"`
+	Behind    style `json:"behind"`
+	Phase     style `json:"phase"`
+	Model     style `json:"model"`
+	Context   style `json:"context"`
+	Cost      style `json:"cost"`
+	Clock     style `json:"clock"`
+	Toolchain style `json:"toolchain"`
+	Env       style `json:"env"`
+	Kube      style `json:"kube"`
+	Cloud     style `json:"cloud"`
+	Danger    style `json:"danger"`
+	Plugin    style `json:"plugin"`
+	Warning   style `json:"warning"`
+}
+
+var themes = map[string]theme{
+	"default": {
+		Project:   style{BG: "238"},
+		Dir:       style{FG: "245", BG: "237"},
+		Branch:    style{BG: "236"},
+		Clean:     style{FG: "82", Bold: true},
+		Tracked:   style{FG: "220", Bold: true},
+		Untracked: style{FG: "196", Bold: true},
+		Ahead:     style{FG: "82", BG: "234"},
+		Behind:    style{FG: "196", BG: "234"},
+		Phase:     style{FG: "245"},
+		Model:     style{FG: "111", BG: "237"},
+		Context:   style{FG: "250", BG: "236"},
+		Cost:      style{FG: "250", BG: "235"},
+		Clock:     style{FG: "245", BG: "234"},
+		Toolchain: style{FG: "109", BG: "236"},
+		Env:       style{FG: "178", BG: "236"},
+		Kube:      style{FG: "75", BG: "237"},
+		Cloud:     style{FG: "180", BG: "237"},
+		Danger:    style{FG: "196", BG: "52", Bold: true},
+		Plugin:    style{FG: "250", BG: "236"},
+		Warning:   style{FG: "208", Bold: true},
+	},
+	"solarized": {
+		Project:   style{FG: "33", BG: "240", Bold: true},
+		Dir:       style{FG: "245", BG: "238"},
+		Branch:    style{FG: "245", BG: "236"},
+		Clean:     style{FG: "64", Bold: true},
+		Tracked:   style{FG: "136", Bold: true},
+		Untracked: style{FG: "160", Bold: true},
+		Ahead:     style{FG: "64", BG: "235"},
+		Behind:    style{FG: "160", BG: "235"},
+		Phase:     style{FG: "61"},
+		Model:     style{FG: "61", BG: "236"},
+		Context:   style{FG: "37", BG: "235"},
+		Cost:      style{FG: "245", BG: "234"},
+		Clock:     style{FG: "245", BG: "234"},
+		Toolchain: style{FG: "37", BG: "236"},
+		Env:       style{FG: "136", BG: "236"},
+		Kube:      style{FG: "33", BG: "236"},
+		Cloud:     style{FG: "136", BG: "235"},
+		Danger:    style{FG: "160", BG: "52", Bold: true},
+		Plugin:    style{FG: "245", BG: "236"},
+		Warning:   style{FG: "166", Bold: true},
+	},
+	"catppuccin": {
+		Project:   style{FG: "#cba6f7", BG: "#45475a", Bold: true},
+		Dir:       style{FG: "#a6adc8", BG: "#313244"},
+		Branch:    style{FG: "#cdd6f4", BG: "#313244"},
+		Clean:     style{FG: "#a6e3a1", Bold: true},
+		Tracked:   style{FG: "#f9e2af", Bold: true},
+		Untracked: style{FG: "#f38ba8", Bold: true},
+		Ahead:     style{FG: "#a6e3a1", BG: "#181825"},
+		Behind:    style{FG: "#f38ba8", BG: "#181825"},
+		Phase:     style{FG: "#b4befe"},
+		Model:     style{FG: "#89b4fa", BG: "#45475a"},
+		Context:   style{FG: "#94e2d5", BG: "#313244"},
+		Cost:      style{FG: "#bac2de", BG: "#181825"},
+		Clock:     style{FG: "#a6adc8", BG: "#181825"},
+		Toolchain: style{FG: "#89dceb", BG: "#313244"},
+		Env:       style{FG: "#f9e2af", BG: "#313244"},
+		Kube:      style{FG: "#74c7ec", BG: "#313244"},
+		Cloud:     style{FG: "#fab387", BG: "#313244"},
+		Danger:    style{FG: "#f38ba8", BG: "#45273a", Bold: true},
+		Plugin:    style{FG: "#cdd6f4", BG: "#313244"},
+		Warning:   style{FG: "#fab387", Bold: true},
+	},
+	"gruvbox": {
+		Project:   style{FG: "214", BG: "239", Bold: true},
+		Dir:       style{FG: "246", BG: "238"},
+		Branch:    style{FG: "223", BG: "237"},
+		Clean:     style{FG: "142", Bold: true},
+		Tracked:   style{FG: "214", Bold: true},
+		Untracked: style{FG: "167", Bold: true},
+		Ahead:     style{FG: "142", BG: "235"},
+		Behind:    style{FG: "167", BG: "235"},
+		Phase:     style{FG: "175"},
+		Model:     style{FG: "109", BG: "239"},
+		Context:   style{FG: "108", BG: "237"},
+		Cost:      style{FG: "223", BG: "235"},
+		Clock:     style{FG: "246", BG: "235"},
+		Toolchain: style{FG: "108", BG: "237"},
+		Env:       style{FG: "214", BG: "237"},
+		Kube:      style{FG: "109", BG: "237"},
+		Cloud:     style{FG: "175", BG: "237"},
+		Danger:    style{FG: "167", BG: "52", Bold: true},
+		Plugin:    style{FG: "223", BG: "237"},
+		Warning:   style{FG: "208", Bold: true},
+	},
+	"monochrome": {
+		Project:   style{BG: "238", Bold: true},
+		Dir:       style{BG: "237"},
+		Branch:    style{BG: "236"},
+		Tracked:   style{Bold: true},
+		Untracked: style{Bold: true},
+		Ahead:     style{BG: "234"},
+		Behind:    style{BG: "234"},
+		Phase:     style{},
+		Model:     style{BG: "238"},
+		Context:   style{BG: "236"},
+		Cost:      style{BG: "234"},
+		Clock:     style{BG: "234"},
+		Toolchain: style{BG: "236"},
+		Env:       style{BG: "236", Bold: true},
+		Kube:      style{BG: "237"},
+		Cloud:     style{BG: "237"},
+		Danger:    style{BG: "240", Bold: true},
+		Plugin:    style{BG: "236"},
+		Warning:   style{Bold: true},
+	},
+}
+
+// loadTheme returns the named theme. A file themes/<name>.json in the config
+// directory takes precedence over a built-in theme of the same name. On error
+// the default theme is returned alongside it.
+func loadTheme(dir, name string) (theme, error) {
+	if name == "" {
+		name = "default"
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return themes["default"], fmt.Errorf("invalid theme name %q", name)
+	}
+	if dir != "" {
+		b, err := os.ReadFile(filepath.Join(dir, "themes", name+".json"))
+		if err == nil {
+			return parseTheme(b)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return themes["default"], err
+		}
+	}
+	if t, ok := themes[name]; ok {
+		return t, nil
+	}
+	return themes["default"], fmt.Errorf("unknown theme %q", name)
+}
+
+// parseTheme decodes a theme file. The file starts from the built-in theme
+// named by its "base" key (default if absent), so it only has to list the
+// styles, or the parts of a style, it changes.
+func parseTheme(b []byte) (theme, error) {
+	var head struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(b, &head); err != nil {
+		return themes["default"], err
+	}
+	if head.Base == "" {
+		head.Base = "default"
+	}
+	t, ok := themes[head.Base]
+	if !ok {
+		return themes["default"], fmt.Errorf("unknown base theme %q", head.Base)
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return themes["default"], err
+	}
+	return t, nil
+}
+
+// sgr returns the SGR parameters for st, e.g. "1;38;5;82".
+func (st style) sgr() string {
+	var p []string
+	if st.Bold {
+		p = append(p, "1")
+	}
+	if c := colorCode(st.FG); c != "" {
+		p = append(p, "38;"+c)
+	}
+	if c := colorCode(st.BG); c != "" {
+		p = append(p, "48;"+c)
+	}
+	return strings.Join(p, ";")
+}
+
+// colorCode converts a theme color to the part of an SGR sequence following
+// 38 or 48. Invalid colors yield "".
+func colorCode(c string) string {
+	if strings.HasPrefix(c, "#") && len(c) == 7 {
+		rgb, err := strconv.ParseUint(c[1:], 16, 32)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("2;%d;%d;%d", rgb>>16, rgb>>8&0xff, rgb&0xff)
+	}
+	if n, err := strconv.Atoi(c); err == nil && n >= 0 && n <= 255 {
+		return "5;" + c
+	}
+	return ""
+}
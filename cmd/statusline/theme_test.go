@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColorCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"256-color index", "82", "5;82"},
+		{"zero index", "0", "5;0"},
+		{"hex color", "#a6e3a1", "2;166;227;161"},
+		{"empty", "", ""},
+		{"index out of range", "256", ""},
+		{"short hex", "#fff", ""},
+		{"invalid hex", "#gggggg", ""},
+		{"name", "red", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, colorCode(tt.input))
+		})
+	}
+}
+
+func TestStyleSGR(t *testing.T) {
+	assert.Equal(t, "", style{}.sgr())
+	assert.Equal(t, "1", style{Bold: true}.sgr())
+	assert.Equal(t, "1;38;5;82;48;5;236", style{FG: "82", BG: "236", Bold: true}.sgr())
+}
+
+func TestBuiltinThemes(t *testing.T) {
+	for _, name := range []string{"default", "solarized", "catppuccin", "gruvbox", "monochrome"} {
+		t.Run(name, func(t *testing.T) {
+			th, err := loadTheme("", name)
+			require.NoError(t, err)
+			assert.Equal(t, themes[name], th)
+		})
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "themes"), 0o755))
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "themes", name+".json"), []byte(content), 0o644))
+	}
+
+	t.Run("empty name is default", func(t *testing.T) {
+		th, err := loadTheme(dir, "")
+		require.NoError(t, err)
+		assert.Equal(t, themes["default"], th)
+	})
+
+	t.Run("custom theme inherits base", func(t *testing.T) {
+		write("mine", `{"base": "gruvbox", "project": {"fg": "#ffffff"}}`)
+		th, err := loadTheme(dir, "mine")
+		require.NoError(t, err)
+		assert.Equal(t, style{FG: "#ffffff", BG: "239", Bold: true}, th.Project)
+		assert.Equal(t, themes["gruvbox"].Branch, th.Branch)
+	})
+
+	t.Run("custom theme defaults to default base", func(t *testing.T) {
+		write("plain", `{"branch": {"bold": true}}`)
+		th, err := loadTheme(dir, "plain")
+		require.NoError(t, err)
+		assert.Equal(t, style{BG: "236", Bold: true}, th.Branch)
+		assert.Equal(t, themes["default"].Clean, th.Clean)
+	})
+
+	t.Run("custom file overrides builtin", func(t *testing.T) {
+		write("solarized", `{"clean": {"fg": "1"}}`)
+		th, err := loadTheme(dir, "solarized")
+		require.NoError(t, err)
+		assert.Equal(t, "1", th.Clean.FG)
+	})
+
+	t.Run("unknown base", func(t *testing.T) {
+		write("broken", `{"base": "nope"}`)
+		th, err := loadTheme(dir, "broken")
+		assert.Error(t, err)
+		assert.Equal(t, themes["default"], th)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		write("invalid", `{`)
+		th, err := loadTheme(dir, "invalid")
+		assert.Error(t, err)
+		assert.Equal(t, themes["default"], th)
+	})
+
+	t.Run("unknown theme", func(t *testing.T) {
+		th, err := loadTheme(dir, "missing")
+		assert.Error(t, err)
+		assert.Equal(t, themes["default"], th)
+	})
+
+	t.Run("path in name", func(t *testing.T) {
+		_, err := loadTheme(dir, "../config")
+		assert.Error(t, err)
+	})
+}
+
+func TestRenderTheme(t *testing.T) {
+	ri := repoInfo{Project: "myproject", Branch: "main", IsRepo: true, HasTracked: true}
+	result := render(ri, input{}, renderOptions{Color: true, Theme: themes["gruvbox"], Icons: iconSets["unicode"]})
+	expected := "\x1b[1;38;5;214mmyproject\x1b[0m on \x1b[1;38;5;214m⎇\x1b[0m \x1b[38;5;223mmain\x1b[0m"
+	assert.Equal(t, expected, result)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// toolchainFiles lists per toolchain the files that pin its version, in
+// order of preference within a directory.
+var toolchainFiles = map[string][]string{
+	"go":     {"go.mod"},
+	"node":   {".nvmrc", ".node-version", "package.json"},
+	"python": {".python-version"},
+	"rust":   {"rust-toolchain.toml", "rust-toolchain"},
+}
+
+// toolchainNames are the segment names of the toolchains.
+var toolchainNames = []string{"go", "node", "python", "rust"}
+
+type toolchainCache struct {
+	Key      string
+	Versions map[string]string
+}
+
+// detectToolchains returns the version of every toolchain pinned in dir or a
+// parent up to root. Results are cached per root and reused while the
+// pinning files, the active virtualenv and GOTOOLCHAIN are unchanged, so `go
+// env` only runs when go.mod changes.
+func detectToolchains(dir, root string) map[string]string {
+	files := map[string]string{}
+	for tc, names := range toolchainFiles {
+		if f := findFileUp(dir, root, names); f != "" {
+			files[tc] = f
+		}
+	}
+	venv := os.Getenv("VIRTUAL_ENV")
+	key := toolchainKey(files, venv)
+
+	path := cachePath("toolchain", root)
+	var c toolchainCache
+	if readCache(path, &c) && c.Key == key {
+		return c.Versions
+	}
+
+	v := map[string]string{}
+	if f, ok := files["go"]; ok {
+		v["go"] = goVersion(f)
+	}
+	if f, ok := files["node"]; ok {
+		v["node"] = nodeVersion(f)
+	}
+	if py := pythonVersion(venv, files["python"]); py != "" {
+		v["python"] = py
+	}
+	if f, ok := files["rust"]; ok {
+		v["rust"] = rustVersion(f)
+	}
+	for tc, ver := range v {
+		if ver == "" {
+			delete(v, tc)
+		}
+	}
+	writeCache(path, toolchainCache{Key: key, Versions: v})
+	return v
+}
+
+// findFileUp returns the nearest of names from dir upwards, stopping at root.
+func findFileUp(dir, root string, names []string) string {
+	for {
+		for _, n := range names {
+			p := filepath.Join(dir, n)
+			if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+				return p
+			}
+		}
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// toolchainKey fingerprints everything the detected versions depend on.
+func toolchainKey(files map[string]string, venv string) string {
+	var parts []string
+	for _, f := range files {
+		parts = append(parts, statKey(f))
+	}
+	if venv != "" {
+		parts = append(parts, statKey(filepath.Join(venv, "pyvenv.cfg")))
+	}
+	sort.Strings(parts)
+	parts = append(parts, "GOTOOLCHAIN="+os.Getenv("GOTOOLCHAIN"))
+	return strings.Join(parts, "|")
+}
+
+func statKey(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return path
+	}
+	return fmt.Sprintf("%s:%d:%d", path, fi.ModTime().UnixNano(), fi.Size())
+}
+
+// goVersion asks the go command for the active toolchain, which honors the
+// toolchain line and GOTOOLCHAIN, and falls back to go.mod's directives.
+func goVersion(gomod string) string {
+	if v := run(filepath.Dir(gomod), "go", "env", "GOVERSION"); strings.HasPrefix(v, "go") {
+		return strings.TrimPrefix(v, "go")
+	}
+	var goLine, toolchain string
+	forEachLine(gomod, func(ln string) {
+		f := strings.Fields(ln)
+		if len(f) == 2 && f[0] == "go" {
+			goLine = f[1]
+		}
+		if len(f) == 2 && f[0] == "toolchain" {
+			toolchain = strings.TrimPrefix(f[1], "go")
+		}
+	})
+	if toolchain != "" {
+		return toolchain
+	}
+	return goLine
+}
+
+// nodeVersion reads .nvmrc or .node-version, or the engines.node range of
+// package.json.
+func nodeVersion(path string) string {
+	if filepath.Base(path) != "package.json" {
+		return firstLine(path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		Engines struct {
+			Node string `json:"node"`
+		} `json:"engines"`
+	}
+	if json.Unmarshal(b, &pkg) != nil {
+		return ""
+	}
+	return pkg.Engines.Node
+}
+
+// pythonVersion describes the active virtualenv as "3.12.1 (name)" using its
+// pyvenv.cfg, or falls back to .python-version.
+func pythonVersion(venv, pinFile string) string {
+	if venv != "" {
+		var ver string
+		forEachLine(filepath.Join(venv, "pyvenv.cfg"), func(ln string) {
+			k, v, ok := strings.Cut(ln, "=")
+			k = strings.TrimSpace(k)
+			if ok && (k == "version" || k == "version_info") && ver == "" {
+				ver = strings.TrimSpace(v)
+			}
+		})
+		name := filepath.Base(venv)
+		if ver == "" {
+			return "(" + name + ")"
+		}
+		return ver + " (" + name + ")"
+	}
+	if pinFile != "" {
+		return firstLine(pinFile)
+	}
+	return ""
+}
+
+// rustVersion reads the channel of rust-toolchain.toml, or the legacy
+// rust-toolchain file holding just the channel.
+func rustVersion(path string) string {
+	if filepath.Base(path) == "rust-toolchain" {
+		if v := firstLine(path); !strings.HasPrefix(v, "[") {
+			return v
+		}
+	}
+	var channel string
+	forEachLine(path, func(ln string) {
+		k, v, ok := strings.Cut(ln, "=")
+		if ok && strings.TrimSpace(k) == "channel" {
+			channel = strings.Trim(strings.TrimSpace(v), `"'`)
+		}
+	})
+	return channel
+}
+
+func firstLine(path string) string {
+	var first string
+	forEachLine(path, func(ln string) {
+		if first == "" && !strings.HasPrefix(ln, "#") {
+			first = ln
+		}
+	})
+	return first
+}
+
+// forEachLine calls f with every trimmed, non-empty line of the file.
+func forEachLine(path string, f func(string)) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer fh.Close()
+	sc := bufio.NewScanner(fh)
+	for sc.Scan() {
+		if ln := strings.TrimSpace(sc.Text()); ln != "" {
+			f(ln)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestToolchainVersions(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		parse    func(string) string
+		expected string
+	}{
+		{"nvmrc", ".nvmrc", "v20.11.0\n", nodeVersion, "v20.11.0"},
+		{"node-version", ".node-version", "# pinned\n18\n", nodeVersion, "18"},
+		{"package.json engines", "package.json", `{"engines":{"node":">=20"}}`, nodeVersion, ">=20"},
+		{"package.json without engines", "package.json", `{"name":"x"}`, nodeVersion, ""},
+		{"rust-toolchain.toml", "rust-toolchain.toml", "[toolchain]\nchannel = \"1.79.0\"\n", rustVersion, "1.79.0"},
+		{"legacy rust-toolchain", "rust-toolchain", "nightly-2024-05-01\n", rustVersion, "nightly-2024-05-01"},
+		{"legacy rust-toolchain in toml syntax", "rust-toolchain", "[toolchain]\nchannel = 'stable'\n", rustVersion, "stable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(dir, tt.name, tt.file)
+			writeFile(t, p, tt.content)
+			assert.Equal(t, tt.expected, tt.parse(p))
+		})
+	}
+}
+
+func TestGoVersionFromGoMod(t *testing.T) {
+	// without a go command the directives are used
+	t.Setenv("PATH", "")
+	dir := t.TempDir()
+
+	p := filepath.Join(dir, "a", "go.mod")
+	writeFile(t, p, "module a\n\ngo 1.22\n")
+	assert.Equal(t, "1.22", goVersion(p))
+
+	p = filepath.Join(dir, "b", "go.mod")
+	writeFile(t, p, "module b\n\ngo 1.22\n\ntoolchain go1.23.4\n")
+	assert.Equal(t, "1.23.4", goVersion(p))
+}
+
+func TestPythonVersion(t *testing.T) {
+	dir := t.TempDir()
+	venv := filepath.Join(dir, ".venv")
+	writeFile(t, filepath.Join(venv, "pyvenv.cfg"), "home = /usr/bin\nversion_info = 3.12.1.final.0\n")
+	pin := filepath.Join(dir, ".python-version")
+	writeFile(t, pin, "3.11\n")
+
+	assert.Equal(t, "3.12.1.final.0 (.venv)", pythonVersion(venv, pin))
+	assert.Equal(t, "3.11", pythonVersion("", pin))
+	assert.Equal(t, "(env)", pythonVersion(filepath.Join(dir, "env"), ""))
+	assert.Equal(t, "", pythonVersion("", ""))
+}
+
+func TestDetectToolchains(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	t.Setenv("VIRTUAL_ENV", "")
+	t.Setenv("PATH", "")
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "go.mod"), "module x\n\ngo 1.22\n")
+	writeFile(t, filepath.Join(root, "web", ".nvmrc"), "20\n")
+	writeFile(t, filepath.Join(root, "web", "app", "index.js"), "")
+	// above the root, so not part of the project
+	writeFile(t, filepath.Join(filepath.Dir(root), "rust-toolchain"), "stable\n")
+
+	cwd := filepath.Join(root, "web", "app")
+	assert.Equal(t, map[string]string{"go": "1.22", "node": "20"}, detectToolchains(cwd, root))
+
+	// served from the cache until a pinning file changes
+	var c toolchainCache
+	require.True(t, readCache(cachePath("toolchain", root), &c))
+	assert.Equal(t, "1.22", c.Versions["go"])
+
+	writeFile(t, filepath.Join(root, "web", ".nvmrc"), "22.1\n")
+	assert.Equal(t, map[string]string{"go": "1.22", "node": "22.1"}, detectToolchains(cwd, root))
+}
+
+func TestToolchainSegment(t *testing.T) {
+	opts := renderOptions{Theme: themes["default"], Icons: iconSets["ascii"]}
+
+	_, ok := segmentFuncs["go"](repoInfo{}, input{}, opts)
+	assert.False(t, ok)
+
+	ri := repoInfo{Toolchains: map[string]string{"go": "1.24.1", "python": "3.12.1 (.venv)"}}
+	assert.Equal(t, "go 1.24.1 py 3.12.1 (.venv)",
+		render(ri, input{}, renderOptions{Icons: iconSets["ascii"], Lines: parseLines("go,node,python,rust")}))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Untracked file modes. no skips the untracked scan, which dominates git
+// status in large trees; auto does so in repositories where it was found to
+// be slow.
+const (
+	untrackedNormal = "normal"
+	untrackedNo     = "no"
+	untrackedAuto   = "auto"
+)
+
+const (
+	// slowStatus is how long git status may take in auto mode before the
+	// untracked scan is skipped for the repository.
+	slowStatus = 150 * time.Millisecond
+
+	// relearnAfter is how long a repository keeps skipping the untracked
+	// scan before auto mode measures it again.
+	relearnAfter = 10 * time.Minute
+)
+
+// gitOptions are set from the config in main.
+var gitOptions = struct {
+	Untracked string // untrackedNormal, untrackedNo or untrackedAuto
+	FSMonitor bool   // use git's builtin file system monitor
+}{Untracked: untrackedNormal}
+
+type untrackedState struct {
+	SkipUntil time.Time
+}
+
+// gitStatus runs git status in the configured untracked mode. unknown
+// reports that untracked files were not looked for. In auto mode, a status
+// that is slow or times out makes the repository skip the untracked scan
+// for relearnAfter.
+func gitStatus(root string) (status string, unknown bool, err error) {
+	switch gitOptions.Untracked {
+	case untrackedNo:
+		status, err = gitErr(root, statusArgs(untrackedNo)...)
+		return status, true, err
+	case untrackedAuto:
+	default:
+		status, err = gitErr(root, statusArgs(untrackedNormal)...)
+		return status, false, err
+	}
+
+	path := cachePath("untracked", root)
+	var st untrackedState
+	if readCache(path, &st) && time.Now().Before(st.SkipUntil) {
+		debugf("skipping untracked files in %s until %s", root, st.SkipUntil.Format(time.TimeOnly))
+		status, err = gitErr(root, statusArgs(untrackedNo)...)
+		return status, true, err
+	}
+	start := time.Now()
+	status, err = gitErr(root, statusArgs(untrackedNormal)...)
+	if !errors.Is(err, errTimeout) && time.Since(start) < slowStatus {
+		if !st.SkipUntil.IsZero() {
+			_ = os.Remove(path)
+		}
+		return status, false, err
+	}
+	debugf("git status in %s took %v; skipping untracked files for %v", root, time.Since(start).Round(time.Millisecond), relearnAfter)
+	writeCache(path, untrackedState{SkipUntil: time.Now().Add(relearnAfter)})
+	if err != nil {
+		// timed out: without the untracked scan there is still a branch
+		status, err = gitErr(root, statusArgs(untrackedNo)...)
+		return status, true, err
+	}
+	return status, false, nil
+}
+
+func statusArgs(mode string) []string {
+	var args []string
+	if gitOptions.FSMonitor {
+		args = append(args, "-c", "core.fsmonitor=true")
+	}
+	if mode != untrackedNo {
+		args = append(args, "-c", "core.untrackedCache=true")
+	}
+	return append(args, "status", "--porcelain=2", "--branch", "--ignore-submodules=dirty", "--untracked-files="+mode)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setGitOptions(t *testing.T, untracked string, fsmonitor bool) {
+	t.Helper()
+	old := gitOptions
+	t.Cleanup(func() { gitOptions = old })
+	gitOptions.Untracked, gitOptions.FSMonitor = untracked, fsmonitor
+}
+
+func TestStatusArgs(t *testing.T) {
+	setGitOptions(t, untrackedNormal, false)
+	assert.Equal(t, []string{"-c", "core.untrackedCache=true", "status", "--porcelain=2", "--branch", "--ignore-submodules=dirty", "--untracked-files=normal"},
+		statusArgs(untrackedNormal))
+	assert.Equal(t, []string{"status", "--porcelain=2", "--branch", "--ignore-submodules=dirty", "--untracked-files=no"},
+		statusArgs(untrackedNo))
+
+	gitOptions.FSMonitor = true
+	assert.Equal(t, []string{"-c", "core.fsmonitor=true", "status", "--porcelain=2", "--branch", "--ignore-submodules=dirty", "--untracked-files=no"},
+		statusArgs(untrackedNo))
+}
+
+func TestGitStatusModes(t *testing.T) {
+	t.Setenv("STATUSLINE_CACHE_DIR", t.TempDir())
+	root := testRepo(t)
+
+	t.Run("normal", func(t *testing.T) {
+		setGitOptions(t, untrackedNormal, false)
+		status, unknown, err := gitStatus(root)
+		require.NoError(t, err)
+		assert.False(t, unknown)
+		assert.Contains(t, status, "? a.txt")
+	})
+
+	t.Run("no", func(t *testing.T) {
+		setGitOptions(t, untrackedNo, false)
+		status, unknown, err := gitStatus(root)
+		require.NoError(t, err)
+		assert.True(t, unknown)
+		assert.Contains(t, status, "# branch.head main")
+		assert.NotContains(t, status, "a.txt")
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		setGitOptions(t, untrackedAuto, false)
+		path := cachePath("untracked", root)
+
+		_, unknown, err := gitStatus(root)
+		require.NoError(t, err)
+		assert.False(t, unknown, "fast repository")
+		assert.NoFileExists(t, path)
+
+		writeCache(path, untrackedState{SkipUntil: time.Now().Add(time.Minute)})
+		status, unknown, err := gitStatus(root)
+		require.NoError(t, err)
+		assert.True(t, unknown, "learned to be slow")
+		assert.NotContains(t, status, "a.txt")
+
+		writeCache(path, untrackedState{SkipUntil: time.Now().Add(-time.Minute)})
+		_, unknown, _ = gitStatus(root)
+		assert.False(t, unknown, "measured again")
+		assert.NoFileExists(t, path)
+	})
+}
+
+func TestVCSSegmentUntrackedUnknown(t *testing.T) {
+	opts := renderOptions{Icons: iconSets["unicode"]}
+	ri := repoInfo{Project: "big", Branch: "main", IsRepo: true, UntrackedUnknown: true}
+	assert.Equal(t, "big on ⎇ main ?", render(ri, input{}, opts))
+}
+
+func TestConfigUntracked(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"untracked": "auto", "fsmonitor": true}`), 0o644))
+	t.Setenv("STATUSLINE_UNTRACKED", "")
+	t.Setenv("STATUSLINE_FSMONITOR", "")
+	cfg, err := loadConfig(dir)
+	require.NoError(t, err)
+	assert.Equal(t, untrackedAuto, cfg.Untracked)
+	assert.True(t, cfg.FSMonitor)
+
+	t.Setenv("STATUSLINE_UNTRACKED", "no")
+	t.Setenv("STATUSLINE_FSMONITOR", "0")
+	cfg, _ = loadConfig(dir)
+	assert.Equal(t, untrackedNo, cfg.Untracked)
+	assert.False(t, cfg.FSMonitor)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// vcs is a version control backend.
+type vcs interface {
+	// Name identifies the backend in repoInfo.VCS.
+	Name() string
+	// Root returns the root of the repository containing dir.
+	Root(dir string) (string, bool)
+	// Collect gathers the state of the repository at root.
+	Collect(root string) repoInfo
+}
+
+// backends are tried in order. jj comes before git because a colocated jj
+// repository is also a git repository, and git only sees a detached HEAD.
+var backends = []vcs{
+	jjBackend{},
+	hgBackend{name: "sl", cmd: "sl", marker: ".sl"},
+	hgBackend{name: "hg", cmd: "hg", marker: ".hg"},
+	gitBackend{},
+}
+
+// detectVCS returns the backend of the innermost repository containing dir
+// and its root. On equal roots the earlier backend wins.
+func detectVCS(dir string) (vcs, string) {
+	var (
+		best     vcs
+		bestRoot string
+	)
+	for _, b := range backends {
+		if root, ok := b.Root(dir); ok && len(root) > len(bestRoot) {
+			best, bestRoot = b, root
+		}
+	}
+	return best, bestRoot
+}
+
+// findUp returns the nearest directory from dir upwards that contains an
+// entry called name. Symlinks are resolved like git does for its root, so
+// roots from different backends compare equal.
+func findUp(dir, name string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	if d, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = d
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
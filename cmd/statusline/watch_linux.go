@@ -0,0 +1,158 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// maxWatches bounds the inotify watches of one repository; larger ones are
+// polled instead. Replaced in tests.
+var maxWatches = 8192
+
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+var errTooManyWatches = errors.New("too many directories to watch")
+
+// inotifyWatcher watches every directory of a repository, including .git but
+// not its object store.
+type inotifyWatcher struct {
+	f       *os.File
+	fd      int
+	changed func(root string)
+	dropped func(root string)
+
+	mu    sync.Mutex
+	dirs  map[int32]watchedDir
+	count map[string]int // watches per root
+}
+
+type watchedDir struct {
+	root, path string
+}
+
+// newWatcher reports changes below the added roots to changed, and roots
+// that outgrew maxWatches, and are no longer watched, to dropped.
+func newWatcher(changed, dropped func(root string)) (watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		// non-blocking, so reads go through the poller and Close ends them
+		f:       os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		changed: changed,
+		dropped: dropped,
+		dirs:    map[int32]watchedDir{},
+		count:   map[string]int{},
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Add(root string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.addTree(root, root); err != nil {
+		// a partly watched repository would miss changes in the rest
+		w.remove(root)
+		return err
+	}
+	return nil
+}
+
+// remove drops the watches of root.
+func (w *inotifyWatcher) remove(root string) {
+	for wd, d := range w.dirs {
+		if d.root == root {
+			_, _ = syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+	delete(w.count, root)
+}
+
+func (w *inotifyWatcher) Close() error {
+	return w.f.Close()
+}
+
+func (w *inotifyWatcher) addTree(root, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if d.Name() == "objects" && filepath.Base(filepath.Dir(p)) == ".git" {
+			return filepath.SkipDir
+		}
+		if w.count[root] >= maxWatches {
+			return errTooManyWatches
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, p, inotifyMask)
+		if err != nil {
+			// ENOSPC once fs.inotify.max_user_watches is reached
+			return err
+		}
+		w.dirs[int32(wd)] = watchedDir{root, p}
+		w.count[root]++
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) loop() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		roots, dropped := map[string]bool{}, map[string]bool{}
+		w.mu.Lock()
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			size := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			start := off + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[start:min(start+size, n)]), "\x00")
+			off = start + size
+
+			if mask&syscall.IN_Q_OVERFLOW != 0 {
+				for _, d := range w.dirs {
+					roots[d.root] = true
+				}
+				continue
+			}
+			d, ok := w.dirs[wd]
+			if !ok {
+				continue
+			}
+			if mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, wd)
+				w.count[d.root]--
+				continue
+			}
+			roots[d.root] = true
+			if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				if w.addTree(d.root, filepath.Join(d.path, name)) != nil {
+					w.remove(d.root)
+					dropped[d.root] = true
+				}
+			}
+		}
+		w.mu.Unlock()
+		for r := range roots {
+			w.changed(r)
+		}
+		for r := range dropped {
+			w.dropped(r)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInotifyWatcher(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git", "objects"), 0o755))
+
+	changed := make(chan string, 100)
+	w, err := newWatcher(func(r string) { changed <- r }, func(string) {})
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Add(root))
+
+	expect := func(what string) {
+		t.Helper()
+		select {
+		case r := <-changed:
+			require.Equal(t, root, r)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no change reported for", what)
+		}
+		// drain the events of the same change
+		for len(changed) > 0 {
+			<-changed
+		}
+		time.Sleep(20 * time.Millisecond)
+		for len(changed) > 0 {
+			<-changed
+		}
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0o644))
+	expect("new file")
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o644))
+	expect("HEAD")
+
+	// new directories are watched as well
+	require.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0o755))
+	expect("new directory")
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "b.txt"), []byte("b"), 0o644))
+	expect("file in new directory")
+
+	// the object store is not
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "objects", "x"), []byte("x"), 0o644))
+	select {
+	case <-changed:
+		t.Fatal("change in .git/objects reported")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestInotifyWatcherTooManyDirectories(t *testing.T) {
+	old := maxWatches
+	t.Cleanup(func() { maxWatches = old })
+	maxWatches = 4
+
+	root := t.TempDir()
+	for _, d := range []string{".git", "a", "b", "c"} {
+		require.NoError(t, os.Mkdir(filepath.Join(root, d), 0o755))
+	}
+	changed, dropped := make(chan string, 100), make(chan string, 1)
+	w, err := newWatcher(func(r string) { changed <- r }, func(r string) { dropped <- r })
+	require.NoError(t, err)
+	defer w.Close()
+	iw := w.(*inotifyWatcher)
+
+	// the watches added before the limit are removed again
+	require.ErrorIs(t, w.Add(root), errTooManyWatches)
+	iw.mu.Lock()
+	assert.Empty(t, iw.dirs)
+	assert.Zero(t, iw.count[root])
+	iw.mu.Unlock()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "x.txt"), []byte("x"), 0o644))
+	select {
+	case <-changed:
+		t.Fatal("change reported for a repository that is not watched")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// a watched repository that grows past the limit is dropped
+	require.NoError(t, os.Remove(filepath.Join(root, "c")))
+	require.NoError(t, w.Add(root))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "c"), 0o755))
+	select {
+	case r := <-dropped:
+		assert.Equal(t, root, r)
+	case <-time.After(2 * time.Second):
+		t.Fatal("repository not dropped")
+	}
+	iw.mu.Lock()
+	assert.Empty(t, iw.dirs)
+	iw.mu.Unlock()
+}
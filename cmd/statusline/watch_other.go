@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// newWatcher is only implemented for Linux; elsewhere the daemon polls.
+func newWatcher(_, _ func(root string)) (watcher, error) {
+	return nil, errors.New("file watching is only supported on Linux")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// displayWidth returns the number of terminal cells s occupies. SGR and OSC
+// escape sequences take no space, East Asian wide characters and emoji take
+// two cells, combining marks none.
+func displayWidth(s string) int {
+	w := 0
+	for i := 0; i < len(s); {
+		if s[i] == esc[0] && i+1 < len(s) {
+			i += escapeLen(s[i:])
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		w += runeWidth(r)
+		i += size
+	}
+	return w
+}
+
+// escapeLen returns the length of the escape sequence at the start of s.
+func escapeLen(s string) int {
+	switch s[1] {
+	case '[': // CSI, ends with a byte in 0x40-0x7e
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']': // OSC, ends with ST (ESC \) or BEL
+		if i := strings.IndexAny(s[2:], "\a"+esc); i >= 0 {
+			if s[2+i] == '\a' {
+				return 2 + i + 1
+			}
+			return 2 + i + 2
+		}
+	default:
+		return 2
+	}
+	return len(s)
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r == 0x200d, r >= 0xfe00 && r <= 0xfe0f, r >= 0x300 && r <= 0x36f:
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f680 && r <= 0x1f6ff,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
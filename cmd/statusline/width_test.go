@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{"empty", "", 0},
+		{"ascii", "main", 4},
+		{"unicode symbols", "⎇ ↑2", 4},
+		{"sgr", "\x1b[1;38;5;82m⎇\x1b[0m main", 6},
+		{"osc 8 with ST", "\x1b]8;;https://x\x1b\\repo\x1b]8;;\x1b\\", 4},
+		{"osc 8 with BEL", "\x1b]8;;https://x\arepo\x1b]8;;\a", 4},
+		{"cjk", "日本", 4},
+		{"emoji", "🚀", 2},
+		{"combining mark", "é", 1},
+		{"nerd font glyph", "", 1},
+		{"unterminated escape", "a\x1b[38;5", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, displayWidth(tt.input))
+		})
+	}
+}